@@ -2,44 +2,171 @@ package spudo
 
 import (
 	"net/http"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
 )
 
-// AddCommand will add a command that will trigger Exec.
-func (sp *Spudo) AddCommand(name, description string, exec func(author string, args []string) interface{}) {
-	if _, ok := sp.commands[name]; ok {
-		sp.logger.info("Failed to add command: ", name, "- Already exists")
-		return
+// CommandOption configures a command added with AddCommand.
+type CommandOption func(*command)
+
+// NoTyping stops the typing indicator from being shown while the
+// command is running, for commands that respond with nothing or only
+// react.
+func NoTyping() CommandOption {
+	return func(c *command) {
+		c.NoTyping = true
 	}
-	sp.commands[name] = &command{
+}
+
+// Private sends the command's response to the user directly rather
+// than in the channel the command was used in.
+func Private() CommandOption {
+	return func(c *command) {
+		c.PrivateResponse = true
+	}
+}
+
+// AddCommand will add a command that will trigger Exec. opts can
+// change how the command is handled, such as NoTyping or Private.
+// exec only gets the author, so use AddContextCommand for commands
+// that depend on the guild or channel they are used in.
+func (sp *Spudo) AddCommand(name, description string, exec func(author string, args []string) interface{}, opts ...CommandOption) {
+	sp.addCommand(&command{
 		Name:        name,
 		Description: description,
 		Exec:        exec,
+	}, opts)
+}
+
+// AddContextCommand adds a command like AddCommand, but exec is passed
+// an EventContext for the message that used it. ctx.Store() returns
+// the command's values for the guild it was used in.
+func (sp *Spudo) AddContextCommand(name, description string, exec func(ctx *EventContext, args []string) interface{}, opts ...CommandOption) {
+	sp.addCommand(&command{
+		Name:        name,
+		Description: description,
+		ExecContext: exec,
+	}, opts)
+}
+
+func (sp *Spudo) addCommand(c *command, opts []CommandOption) {
+	if _, ok := sp.commands[c.Name]; ok {
+		sp.logger.Warn("Failed to add command - already exists", "command", c.Name)
+		return
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
-	sp.logger.info("Command added: ", name)
+	sp.commands[c.Name] = c
+	sp.logger.Info("Command added", "command", c.Name)
 }
 
 // AddStartupPlugin will trigger exec when the bot initially starts
-// up.
+// up. It is only executed on the first Ready event, not when the
+// gateway reconnects.
 func (sp *Spudo) AddStartupPlugin(name string, exec func()) {
-	p := &startupPlugin{
+	p := &lifecyclePlugin{
 		Name: name,
 		Exec: exec,
 	}
 	sp.startupPlugins = append(sp.startupPlugins, p)
-	sp.logger.info("Startup plugin added: ", name)
+	sp.logger.Info("Startup plugin added", "plugin", name)
+}
+
+// OnEveryReady will trigger exec every time a Ready event is
+// received, including after the gateway reconnects.
+func (sp *Spudo) OnEveryReady(name string, exec func()) {
+	p := &lifecyclePlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.everyReadyPlugins = append(sp.everyReadyPlugins, p)
+	sp.logger.Info("Every ready plugin added", "plugin", name)
+}
+
+// AddShutdownPlugin will trigger exec when the bot is shutting down,
+// before the voice connections and discord session are closed.
+func (sp *Spudo) AddShutdownPlugin(name string, exec func()) {
+	p := &lifecyclePlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.shutdownPlugins = append(sp.shutdownPlugins, p)
+	sp.logger.Info("Shutdown plugin added", "plugin", name)
+}
+
+// OnReconnect will trigger exec when the gateway connection is
+// re-established after a disconnect.
+func (sp *Spudo) OnReconnect(name string, exec func()) {
+	p := &lifecyclePlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.reconnectPlugins = append(sp.reconnectPlugins, p)
+	sp.logger.Info("Reconnect plugin added", "plugin", name)
+}
+
+// OnDisconnect will trigger exec when the gateway connection is
+// lost. It is not triggered when the bot is shutting down.
+func (sp *Spudo) OnDisconnect(name string, exec func()) {
+	p := &lifecyclePlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.disconnectPlugins = append(sp.disconnectPlugins, p)
+	sp.logger.Info("Disconnect plugin added", "plugin", name)
+}
+
+// OnDeadLetter will trigger exec when a message could not be sent
+// after retrying it SendAttempts times, so it can be stored or sent
+// another way.
+func (sp *Spudo) OnDeadLetter(name string, exec func(dl *DeadLetter)) {
+	p := &deadLetterPlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.deadLetterPlugins = append(sp.deadLetterPlugins, p)
+	sp.logger.Info("Dead letter plugin added", "plugin", name)
+}
+
+// OnGuildJoin will trigger exec when the bot is added to a
+// guild. Guilds the bot is already in when it starts up are ignored.
+func (sp *Spudo) OnGuildJoin(name string, exec func(guild *discordgo.Guild)) {
+	p := &guildPlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.guildJoinPlugins = append(sp.guildJoinPlugins, p)
+	sp.logger.Info("Guild join plugin added", "plugin", name)
+}
+
+// OnGuildLeave will trigger exec when the bot is removed from a
+// guild. Guilds that become unavailable due to an outage are ignored.
+func (sp *Spudo) OnGuildLeave(name string, exec func(guild *discordgo.Guild)) {
+	p := &guildPlugin{
+		Name: name,
+		Exec: exec,
+	}
+	sp.guildLeavePlugins = append(sp.guildLeavePlugins, p)
+	sp.logger.Info("Guild leave plugin added", "plugin", name)
 }
 
 // AddTimedMessage will trigger Exec at specific times to send a
-// message.
-func (sp *Spudo) AddTimedMessage(name, cronString string, channels []string, exec func() interface{}) {
+// message. opts can change how it is sent, such as WithWebhook to send
+// it through a webhook.
+func (sp *Spudo) AddTimedMessage(name, cronString string, channels []string, exec func() interface{}, opts ...TimedMessageOption) {
 	p := &timedMessage{
 		Name:       name,
 		Channels:   channels,
 		CronString: cronString,
 		Exec:       exec,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 	sp.timedMessages = append(sp.timedMessages, p)
-	sp.logger.info("Timed message added: ", name)
+	sp.logger.Info("Timed message added", "plugin", name)
 }
 
 // AddUserReaction will add reaction(s) to a user(s) message.
@@ -50,7 +177,7 @@ func (sp *Spudo) AddUserReaction(name string, userIDs, reactionIDs []string) {
 		ReactionIDs: reactionIDs,
 	}
 	sp.userReactions = append(sp.userReactions, p)
-	sp.logger.info("User reaction added: ", name)
+	sp.logger.Info("User reaction added", "plugin", name)
 }
 
 // AddMessageReaction will add reaction(s) when trigger word(s) are in a
@@ -62,16 +189,23 @@ func (sp *Spudo) AddMessageReaction(name string, triggerWords, reactionIDs []str
 		ReactionIDs:  reactionIDs,
 	}
 	sp.messageReactions = append(sp.messageReactions, p)
-	sp.logger.info("Message reaction added: ", name)
+	sp.logger.Info("Message reaction added", "plugin", name)
 }
 
 // AddRESTRoute will add an endpoint at route that will execute exec
-// when used.
-func (sp *Spudo) AddRESTRoute(route string, exec func(w http.ResponseWriter, r *http.Request)) {
+// when used. Segments of route written as {name} match any value,
+// which can be read with PathParam. opts can restrict the route, such
+// as WithMethods to only accept certain HTTP methods, RequireAPIKey
+// to require authentication or WithRateLimit to limit how often it can
+// be used.
+func (sp *Spudo) AddRESTRoute(route string, exec func(w http.ResponseWriter, r *http.Request), opts ...RESTRouteOption) {
 	if !sp.Config.RESTEnabled {
-		sp.logger.info("Failed to add REST route - REST API is disabled")
+		sp.logger.Warn("Failed to add REST route - REST API is disabled", "route", route)
 		return
 	}
-	http.HandleFunc("/"+route, exec)
-	sp.logger.info("REST route added: ", route)
+	rr := sp.restRouter.handle("/"+strings.TrimPrefix(route, "/"), http.HandlerFunc(exec), opts...)
+	// Authentication comes first so rejected requests don't use up the
+	// rate limit of clients that are allowed to use the route
+	rr.handler = sp.withAuth(rr, sp.withRateLimit(rr, rr.handler))
+	sp.logger.Info("REST route added", "route", route)
 }
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
 	"strconv"
 	"sync"
@@ -87,6 +86,18 @@ func (q *mediaQueue) next() error {
 	return errEndOfQueue
 }
 
+// length returns the number of items in the queue that haven't
+// finished playing, including the current one.
+func (q *mediaQueue) length() int {
+	q.Lock()
+	defer q.Unlock()
+
+	if n := len(q.playlist) - q.position; n > 0 {
+		return n
+	}
+	return 0
+}
+
 func (q *mediaQueue) current() (*media, error) {
 	q.Lock()
 	defer q.Unlock()
@@ -123,7 +134,7 @@ func (sp *Spudo) watchForDisconnect() {
 		for _, as := range sp.audioSessions {
 			userCount, err := sp.getListenerCount(as.Voice.GuildID, as.Voice.ChannelID)
 			if err != nil {
-				sp.logger.error("Error getting listener count: ", err)
+				sp.logger.Error("Error getting listener count", "error", err)
 				continue
 			}
 			if userCount <= 1 {
@@ -133,7 +144,7 @@ func (sp *Spudo) watchForDisconnect() {
 
 				err := as.Voice.Disconnect()
 				if err != nil {
-					sp.logger.error("Error disconnecting from voice channel:", err)
+					sp.logger.Error("Error disconnecting from voice channel", "error", err)
 				}
 				sp.removeAudioSession(as.Voice.GuildID)
 			}
@@ -143,7 +154,7 @@ func (sp *Spudo) watchForDisconnect() {
 
 // Returns the number of users in the same voice channel
 func (sp *Spudo) getListenerCount(guildid, channelid string) (count int, err error) {
-	g, err := sp.Session.Guild(guildid)
+	g, err := sp.backend.State().Guild(guildid)
 	if err != nil {
 		return
 	}
@@ -197,7 +208,7 @@ func (sp *Spudo) cmdPlayMedia(author, channel string, args ...string) interface{
 
 	vs, err := sp.getUserVoiceState(author)
 	if err != nil {
-		sp.logger.error("Error getting voice state: ", err)
+		sp.logger.Error("Error getting voice state", "error", err)
 		return voiceCommand("err")
 	}
 
@@ -210,7 +221,7 @@ func (sp *Spudo) cmdPlayMedia(author, channel string, args ...string) interface{
 			if err == errBadVoiceState {
 				return voiceCommand("you must be in a voice channel to use this command")
 			}
-			sp.logger.error("Error joining voice: ", err)
+			sp.logger.Error("Error joining voice", "error", err)
 			return voiceCommand("error joining voice channel")
 		}
 	}
@@ -221,10 +232,10 @@ func (sp *Spudo) cmdPlayMedia(author, channel string, args ...string) interface{
 
 	// If media is actively being played, return the queued message from queueMedia
 	if audioSess.status == statusPlay || audioSess.status == statusPause {
-		return audioSess.queueMedia(args[0], channel)
+		return audioSess.queueMedia(args[0], channel, sp.logger)
 	}
 
-	audioSess.queueMedia(args[0], channel)
+	audioSess.queueMedia(args[0], channel, sp.logger)
 	audioSess.status = statusPlay
 	go audioSess.start(sp.session)
 	return nil
@@ -233,7 +244,7 @@ func (sp *Spudo) cmdPlayMedia(author, channel string, args ...string) interface{
 func (sp *Spudo) cmdPauseMedia(author, channel string, args ...string) interface{} {
 	vs, err := sp.getUserVoiceState(author)
 	if err != nil {
-		sp.logger.error("Error getting voice state: ", err)
+		sp.logger.Error("Error getting voice state", "error", err)
 		return voiceCommand("err")
 	}
 
@@ -260,7 +271,7 @@ func (sp *Spudo) cmdPauseMedia(author, channel string, args ...string) interface
 func (sp *Spudo) cmdSkipMedia(author, channel string, args ...string) interface{} {
 	vs, err := sp.getUserVoiceState(author)
 	if err != nil {
-		sp.logger.error("Error getting voice state: ", err)
+		sp.logger.Error("Error getting voice state", "error", err)
 		return voiceCommand("err")
 	}
 
@@ -281,7 +292,7 @@ func (sp *Spudo) cmdSkipMedia(author, channel string, args ...string) interface{
 }
 
 func (sp *Spudo) getUserVoiceState(userid string) (*discordgo.VoiceState, error) {
-	for _, guild := range sp.Session.State.Guilds {
+	for _, guild := range sp.backend.State().Guilds {
 		for _, vs := range guild.VoiceStates {
 			if vs.UserID == userid {
 				return vs, nil
@@ -296,13 +307,13 @@ func (sp *Spudo) joinUserVoiceChannel(userID string) (*discordgo.VoiceConnection
 	if err != nil {
 		return nil, err
 	}
-	return sp.Session.ChannelVoiceJoin(vs.GuildID, vs.ChannelID, false, true)
+	return sp.backend.JoinVoice(vs.GuildID, vs.ChannelID, false, true)
 }
 
 func (sp *Spudo) userInVoiceChannel(userID string) bool {
 	vc, err := sp.getUserVoiceState(userID)
 	if err != nil {
-		sp.logger.error("Error finding user voice state: ", err)
+		sp.logger.Error("Error finding user voice state", "error", err)
 		return false
 	}
 	sp.Lock()
@@ -315,19 +326,19 @@ func (sp *Spudo) userInVoiceChannel(userID string) bool {
 	return false
 }
 
-func (sa *spAudio) queueMedia(audioLink, channel string) voiceCommand {
+func (sa *spAudio) queueMedia(audioLink, channel string, logger Logger) voiceCommand {
 	a := new(media)
 	var err error
 	a.VideoInfo, err = ytdl.GetVideoInfo(audioLink)
 	if err != nil {
-		log.Println("Error getting video info: ", err)
+		logger.Error("Error getting video info", "error", err)
 		return voiceCommand("failed to add item to queue")
 	}
 
 	format := a.VideoInfo.Formats.Extremes(ytdl.FormatAudioBitrateKey, true)[0]
 	a.dlURL, err = a.VideoInfo.GetDownloadURL(format)
 	if err != nil {
-		log.Println("Error getting download url: ", err)
+		logger.Error("Error getting download url", "error", err)
 		return voiceCommand("failed to add item to queue")
 	}
 
@@ -340,13 +351,14 @@ func (sa *spAudio) queueMedia(audioLink, channel string) voiceCommand {
 func (sa *spAudio) start(sess *session) {
 	err := sa.Voice.Speaking(true)
 	if err != nil {
-		log.Println("Failed setting speaking: ", err)
+		sess.logger.Error("Failed setting speaking", "error", err)
 		return
 	}
 
 	defer func() {
-		err := sa.Voice.Speaking(false)
-		log.Println("Failed to end speaking: ", err)
+		if err := sa.Voice.Speaking(false); err != nil {
+			sess.logger.Error("Failed to end speaking", "error", err)
+		}
 	}()
 
 	options := dca.StdEncodeOptions
@@ -357,13 +369,13 @@ func (sa *spAudio) start(sess *session) {
 	for {
 		audio, err := sa.queue.current()
 		if err != nil {
-			log.Println("Error getting current song in queue: ", err)
+			sess.logger.Error("Error getting current song in queue", "error", err)
 			break
 		}
 
 		encodingSession, err := dca.EncodeFile(audio.dlURL.String(), options)
 		if err != nil {
-			log.Println("Error encoding file: ", err)
+			sess.logger.Error("Error encoding file", "error", err)
 			break
 		}
 		defer encodingSession.Cleanup()
@@ -376,7 +388,7 @@ func (sa *spAudio) start(sess *session) {
 
 		err = sa.send(stream, done)
 		if err != nil {
-			log.Println("Error sending audio: ", err)
+			sess.logger.Error("Error sending audio", "error", err)
 		}
 
 		// If the stop command is issued, the send method
@@ -0,0 +1,61 @@
+package spudo
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded in the audit log for a command.
+const (
+	outcomeOK       = "ok"
+	outcomeCooldown = "cooldown"
+	outcomeUnknown  = "unknown"
+	outcomePanic    = "panic"
+)
+
+// auditEntry is a single line of the audit log.
+type auditEntry struct {
+	Time      time.Time `json:"timestamp"`
+	GuildID   string    `json:"guild"`
+	ChannelID string    `json:"channel"`
+	UserID    string    `json:"user"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args"`
+	Outcome   string    `json:"outcome"`
+	LatencyMS float64   `json:"latency_ms"`
+}
+
+// auditLog writes audit entries such as auditEntry as JSON lines.
+type auditLog struct {
+	sync.Mutex
+	w io.WriteCloser
+}
+
+func newAuditLog(w io.WriteCloser) *auditLog {
+	return &auditLog{w: w}
+}
+
+// record writes entry to the audit log. It is a no-op if the audit log
+// is disabled.
+func (al *auditLog) record(entry interface{}) error {
+	if al == nil {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	al.Lock()
+	defer al.Unlock()
+	_, err = al.w.Write(append(line, '\n'))
+	return err
+}
+
+func (al *auditLog) Close() error {
+	if al == nil {
+		return nil
+	}
+	return al.w.Close()
+}
@@ -0,0 +1,86 @@
+package spudo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestAuditLogRecord(t *testing.T) {
+	var nilLog *auditLog
+	if err := nilLog.record(&auditEntry{Command: "ping"}); err != nil {
+		t.Errorf("Expected a disabled audit log to ignore records - got %v", err)
+	}
+
+	w := &nopWriteCloser{}
+	al := newAuditLog(w)
+	al.record(&auditEntry{Command: "ping", Outcome: outcomeOK})
+	al.record(&auditEntry{Command: "roll", Args: []string{"2d6"}, Outcome: outcomePanic})
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per record - got %q", w.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["command"] != "roll" || entry["outcome"] != "panic" {
+		t.Errorf("Unexpected record %v", entry)
+	}
+}
+
+func TestAuditCommands(t *testing.T) {
+	sp, _, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!command")
+	w := &nopWriteCloser{}
+	sp.audit = newAuditLog(w)
+	sp.Config.CooldownTimer = 60
+	m.GuildID = "guild"
+
+	sp.AddCommand("roll", "rolls dice", func(author string, args []string) interface{} { return "4" })
+	sp.AddCommand("broken", "panics", func(author string, args []string) interface{} { panic("oops") })
+
+	send := func(author, content string) {
+		m.Author.ID = author
+		m.Content = content
+		sp.handleCommand(m)
+	}
+	send("alice", "!roll 2d6")
+	send("alice", "!roll")
+	send("bob", "!missing")
+	send("carol", "!broken")
+
+	want := []auditEntry{
+		{GuildID: "guild", ChannelID: "channel", UserID: "alice", Command: "roll", Args: []string{"2d6"}, Outcome: outcomeOK},
+		{GuildID: "guild", ChannelID: "channel", UserID: "alice", Command: "roll", Args: []string{}, Outcome: outcomeCooldown},
+		{GuildID: "guild", ChannelID: "channel", UserID: "bob", Command: "missing", Args: []string{}, Outcome: outcomeUnknown},
+		{GuildID: "guild", ChannelID: "channel", UserID: "carol", Command: "broken", Args: []string{}, Outcome: outcomePanic},
+	}
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d records - got %q", len(want), w.String())
+	}
+	for i, line := range lines {
+		var got auditEntry
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("Record %d isn't JSON - %s", i, err)
+		}
+		if got.Time.IsZero() || got.LatencyMS < 0 {
+			t.Errorf("Record %d is missing its time or latency: %s", i, line)
+		}
+		w := want[i]
+		if got.GuildID != w.GuildID || got.ChannelID != w.ChannelID || got.UserID != w.UserID ||
+			got.Command != w.Command || strings.Join(got.Args, " ") != strings.Join(w.Args, " ") || got.Outcome != w.Outcome {
+			t.Errorf("Record %d: expected %+v - got %+v", i, w, got)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package spudo
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Maximum size of a request body read for signature verification.
+const maxSignedBodySize = 10 << 20
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+	errMissingSignature   = errors.New("missing signature")
+	errInvalidSignature   = errors.New("invalid signature")
+	errIPNotAllowed       = errors.New("ip address not allowed")
+)
+
+// authCheck verifies a request to a REST route. It returns nil if the
+// request is allowed, otherwise an *authError describing why not.
+type authCheck func(sp *Spudo, r *http.Request) *authError
+
+type authError struct {
+	status int
+	err    error
+}
+
+// restAuditEntry is written to the audit log when a request to a REST
+// route is rejected.
+type restAuditEntry struct {
+	Time    time.Time `json:"timestamp"`
+	Event   string    `json:"event"`
+	Route   string    `json:"route"`
+	Method  string    `json:"method"`
+	Remote  string    `json:"remote"`
+	Outcome string    `json:"outcome"`
+	Reason  string    `json:"reason"`
+}
+
+// RequireAPIKey requires requests to include one of the RESTAPIKeys
+// from the Config, either as a bearer token in the Authorization
+// header or in the X-API-Key header.
+func RequireAPIKey() RESTRouteOption {
+	return func(rr *restRoute) {
+		rr.auth = append(rr.auth, func(sp *Spudo, r *http.Request) *authError {
+			key := r.Header.Get("X-API-Key")
+			if auth := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+				key = strings.TrimPrefix(auth, "Bearer ")
+			}
+			if key == "" {
+				return &authError{http.StatusUnauthorized, errMissingCredentials}
+			}
+			for _, k := range sp.Config.RESTAPIKeys {
+				if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+					return nil
+				}
+			}
+			return &authError{http.StatusUnauthorized, errInvalidCredentials}
+		})
+	}
+}
+
+// RequireGitHubSignature requires requests to be signed the way
+// GitHub signs webhooks, with a hex encoded HMAC-SHA256 of the body in
+// the X-Hub-Signature-256 header. secretName is the key of the secret
+// in the Config's RESTSecrets.
+func RequireGitHubSignature(secretName string) RESTRouteOption {
+	return RequireSignature(secretName, "X-Hub-Signature-256")
+}
+
+// RequireSignature requires requests to include a hex encoded
+// HMAC-SHA256 of the body in header, optionally prefixed with
+// "sha256=". secretName is the key of the secret in the Config's
+// RESTSecrets.
+func RequireSignature(secretName, header string) RESTRouteOption {
+	return func(rr *restRoute) {
+		rr.auth = append(rr.auth, func(sp *Spudo, r *http.Request) *authError {
+			secret, err := sp.restSecret(secretName)
+			if err != nil {
+				return &authError{http.StatusUnauthorized, err}
+			}
+			sig := strings.TrimPrefix(r.Header.Get(header), "sha256=")
+			if sig == "" {
+				return &authError{http.StatusUnauthorized, errMissingSignature}
+			}
+			body, err := readBody(r)
+			if err != nil {
+				return &authError{http.StatusBadRequest, err}
+			}
+			if !validSignature(secret, body, sig) {
+				return &authError{http.StatusUnauthorized, errInvalidSignature}
+			}
+			return nil
+		})
+	}
+}
+
+// RequireSharedSecret requires requests to include the secret itself
+// in header, such as GitLab's X-Gitlab-Token. secretName is the key of
+// the secret in the Config's RESTSecrets.
+func RequireSharedSecret(secretName, header string) RESTRouteOption {
+	return func(rr *restRoute) {
+		rr.auth = append(rr.auth, func(sp *Spudo, r *http.Request) *authError {
+			secret, err := sp.restSecret(secretName)
+			if err != nil {
+				return &authError{http.StatusUnauthorized, err}
+			}
+			got := r.Header.Get(header)
+			if got == "" {
+				return &authError{http.StatusUnauthorized, errMissingCredentials}
+			}
+			if subtle.ConstantTimeCompare([]byte(secret), []byte(got)) != 1 {
+				return &authError{http.StatusUnauthorized, errInvalidCredentials}
+			}
+			return nil
+		})
+	}
+}
+
+// AllowIPs only accepts requests from the given IP addresses or CIDR
+// ranges. Requests from anywhere else receive a 403 response. This is
+// in addition to the RESTAllowedIPs in the Config, which apply to
+// every route.
+func AllowIPs(cidrs ...string) RESTRouteOption {
+	return func(rr *restRoute) {
+		rr.auth = append(rr.auth, func(sp *Spudo, r *http.Request) *authError {
+			return checkIP(r, cidrs)
+		})
+	}
+}
+
+// withAuth wraps next so every auth check of the route has to pass
+// before it is called. Rejected requests are logged to the audit log
+// and receive a JSON error response.
+func (sp *Spudo) withAuth(rr *restRoute, next http.Handler) http.Handler {
+	checks := rr.auth
+	if len(sp.Config.RESTAllowedIPs) > 0 {
+		global := func(sp *Spudo, r *http.Request) *authError {
+			return checkIP(r, sp.Config.RESTAllowedIPs)
+		}
+		checks = append([]authCheck{global}, checks...)
+	}
+	if len(checks) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, check := range checks {
+			if ae := check(sp, r); ae != nil {
+				sp.rejectRequest(w, r, rr.pattern, ae)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (sp *Spudo) rejectRequest(w http.ResponseWriter, r *http.Request, route string, ae *authError) {
+	outcome := "unauthorized"
+	if ae.status == http.StatusForbidden {
+		outcome = "forbidden"
+	}
+	sp.logger.Warn("Rejected REST request", "route", route, "remote", r.RemoteAddr, "reason", ae.err)
+	err := sp.audit.record(&restAuditEntry{
+		Time:    time.Now().UTC(),
+		Event:   "rest_auth",
+		Route:   route,
+		Method:  r.Method,
+		Remote:  r.RemoteAddr,
+		Outcome: outcome,
+		Reason:  ae.err.Error(),
+	})
+	if err != nil {
+		sp.logger.Error("Error writing audit log", "error", err)
+	}
+
+	if ae.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeJSONError(w, ae.status, http.StatusText(ae.status))
+}
+
+func (sp *Spudo) restSecret(name string) (string, error) {
+	secret, ok := sp.Config.RESTSecrets[name]
+	if !ok || secret == "" {
+		sp.logger.Error("REST secret not configured", "secret", name)
+		return "", errors.New("secret " + name + " not configured")
+	}
+	return secret, nil
+}
+
+// readBody reads the request body and replaces it so the handler can
+// read it again.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBodySize))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func validSignature(secret string, body []byte, sig string) bool {
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func checkIP(r *http.Request, allowed []string) *authError {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return &authError{http.StatusForbidden, errIPNotAllowed}
+	}
+	for _, a := range allowed {
+		if strings.Contains(a, "/") {
+			if _, n, err := net.ParseCIDR(a); err == nil && n.Contains(ip) {
+				return nil
+			}
+		} else if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(ip) {
+			return nil
+		}
+	}
+	return &authError{http.StatusForbidden, errIPNotAllowed}
+}
+
+// writeJSONError writes a JSON object with the message as error.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,59 @@
+package spudo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRESTAuth(t *testing.T) {
+	bot := newSpudo()
+	bot.Config.RESTEnabled = true
+	bot.Config.RESTAPIKeys = []string{"key"}
+	bot.Config.RESTSecrets = map[string]string{"github": "secret"}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	bot.AddRESTRoute("keyed", ok, RequireAPIKey())
+	bot.AddRESTRoute("signed", ok, RequireGitHubSignature("github"))
+	bot.AddRESTRoute("local", ok, AllowIPs("10.0.0.0/8"))
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("payload"))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		remote string
+		code   int
+	}{
+		{"bearer token", "/keyed", "Authorization", "Bearer key", "", http.StatusOK},
+		{"api key header", "/keyed", "X-API-Key", "key", "", http.StatusOK},
+		{"wrong key", "/keyed", "X-API-Key", "nope", "", http.StatusUnauthorized},
+		{"no key", "/keyed", "", "", "", http.StatusUnauthorized},
+		{"valid signature", "/signed", "X-Hub-Signature-256", signature, "", http.StatusOK},
+		{"bad signature", "/signed", "X-Hub-Signature-256", "sha256=00", "", http.StatusUnauthorized},
+		{"allowed ip", "/local", "", "", "10.1.2.3:5000", http.StatusOK},
+		{"blocked ip", "/local", "", "", "192.168.1.1:5000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", tt.path, strings.NewReader("payload"))
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		if tt.remote != "" {
+			r.RemoteAddr = tt.remote
+		}
+		w := httptest.NewRecorder()
+		bot.restRouter.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s - got status %d, want %d", tt.name, w.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package spudo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Backend is the connection to Discord that the bot sends and receives
+// everything through. By default it is a discordgo session, but it can
+// be replaced with SetBackend, such as with the fake in the spudotest
+// package so bots can be tested without connecting to Discord.
+type Backend interface {
+	// Open connects to the gateway and Close disconnects from it.
+	Open() error
+	Close() error
+	// AddHandler registers a discordgo event handler, a function that
+	// takes a *discordgo.Session and a pointer to an event struct. The
+	// session may be nil for backends that aren't discordgo.
+	AddHandler(handler interface{}) func()
+	// State is the cache of guilds, channels and the bot's own user.
+	State() *discordgo.State
+	HeartbeatLatency() time.Duration
+
+	SendMessage(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error)
+	EditMessage(channelID, messageID, content string) (*discordgo.Message, error)
+	DeleteMessage(channelID, messageID string) error
+	PinMessage(channelID, messageID string) error
+	AddReaction(channelID, messageID, emojiID string) error
+	Typing(channelID string) error
+	CreateDM(userID string) (*discordgo.Channel, error)
+	JoinVoice(guildID, channelID string, mute, deaf bool) (*discordgo.VoiceConnection, error)
+
+	ChannelWebhooks(channelID string) ([]*discordgo.Webhook, error)
+	CreateWebhook(channelID, name string) (*discordgo.Webhook, error)
+	DeleteWebhook(webhookID string) error
+	ExecuteWebhook(webhookID, token string, params *discordgo.WebhookParams, am *AllowedMentions) (*discordgo.Message, error)
+}
+
+// discordBackend is the Backend used to connect to Discord for real.
+type discordBackend struct {
+	s *discordgo.Session
+}
+
+func (db *discordBackend) Open() error  { return db.s.Open() }
+func (db *discordBackend) Close() error { return db.s.Close() }
+
+func (db *discordBackend) AddHandler(handler interface{}) func() {
+	return db.s.AddHandler(handler)
+}
+
+func (db *discordBackend) State() *discordgo.State         { return db.s.State }
+func (db *discordBackend) HeartbeatLatency() time.Duration { return db.s.HeartbeatLatency() }
+
+func (db *discordBackend) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	return db.s.ChannelMessageEdit(channelID, messageID, content)
+}
+
+func (db *discordBackend) DeleteMessage(channelID, messageID string) error {
+	return db.s.ChannelMessageDelete(channelID, messageID)
+}
+
+func (db *discordBackend) PinMessage(channelID, messageID string) error {
+	return db.s.ChannelMessagePin(channelID, messageID)
+}
+
+func (db *discordBackend) AddReaction(channelID, messageID, emojiID string) error {
+	return db.s.MessageReactionAdd(channelID, messageID, emojiID)
+}
+
+func (db *discordBackend) Typing(channelID string) error {
+	return db.s.ChannelTyping(channelID)
+}
+
+func (db *discordBackend) CreateDM(userID string) (*discordgo.Channel, error) {
+	return db.s.UserChannelCreate(userID)
+}
+
+func (db *discordBackend) JoinVoice(guildID, channelID string, mute, deaf bool) (*discordgo.VoiceConnection, error) {
+	return db.s.ChannelVoiceJoin(guildID, channelID, mute, deaf)
+}
+
+func (db *discordBackend) ChannelWebhooks(channelID string) ([]*discordgo.Webhook, error) {
+	return db.s.ChannelWebhooks(channelID)
+}
+
+func (db *discordBackend) CreateWebhook(channelID, name string) (*discordgo.Webhook, error) {
+	return db.s.WebhookCreate(channelID, name, "")
+}
+
+func (db *discordBackend) DeleteWebhook(webhookID string) error {
+	return db.s.WebhookDelete(webhookID)
+}
+
+// messagePayload is a MessageSend with the allowed mentions discordgo
+// doesn't support yet.
+type messagePayload struct {
+	*discordgo.MessageSend
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// SendMessage does the same as ChannelMessageSendComplex from
+// discordgo, but includes am in the request.
+func (db *discordBackend) SendMessage(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error) {
+	if ms.Embed != nil && ms.Embed.Type == "" {
+		ms.Embed.Type = "rich"
+	}
+	payload := &messagePayload{MessageSend: ms, AllowedMentions: am.normalize()}
+	endpoint := discordgo.EndpointChannelMessages(channelID)
+
+	var response []byte
+	var err error
+	if len(ms.Files) == 0 {
+		response, err = db.s.RequestWithBucketID("POST", endpoint, payload, endpoint)
+	} else {
+		var body []byte
+		var contentType string
+		if body, contentType, err = multipartMessage(payload, ms.Files); err != nil {
+			return nil, err
+		}
+		response, err = db.s.RequestWithLockedBucket("POST", endpoint, contentType, body, db.s.Ratelimiter.LockBucket(endpoint), 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalMessage(response)
+}
+
+// webhookPayload is WebhookParams with the allowed mentions discordgo
+// doesn't support yet.
+type webhookPayload struct {
+	*discordgo.WebhookParams
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// ExecuteWebhook does the same as WebhookExecute from discordgo, but
+// includes am in the request.
+func (db *discordBackend) ExecuteWebhook(webhookID, token string, params *discordgo.WebhookParams, am *AllowedMentions) (*discordgo.Message, error) {
+	payload := &webhookPayload{WebhookParams: params, AllowedMentions: am.normalize()}
+	uri := discordgo.EndpointWebhookToken(webhookID, token) + "?wait=true"
+	response, err := db.s.RequestWithBucketID("POST", uri, payload, discordgo.EndpointWebhookToken("", ""))
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalMessage(response)
+}
+
+func unmarshalMessage(response []byte) (*discordgo.Message, error) {
+	m := &discordgo.Message{}
+	if err := json.Unmarshal(response, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// multipartMessage encodes payload and files as a multipart form, the
+// way Discord expects messages with attachments.
+func multipartMessage(payload interface{}, files []*discordgo.File) ([]byte, string, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="payload_json"`)
+	h.Set("Content-Type", "application/json")
+	p, err := w.CreatePart(h)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := json.NewEncoder(p).Encode(payload); err != nil {
+		return nil, "", err
+	}
+
+	for i, file := range files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file%d"; filename="%s"`, i, quoteEscaper.Replace(file.Name)))
+		h.Set("Content-Type", contentType)
+		p, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.Copy(p, file.Reader); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), w.FormDataContentType(), nil
+}
@@ -0,0 +1,94 @@
+package spudo
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discord's limit on the length of a message.
+const messageLimit = 2000
+
+// coalescer batches messages sent to a channel within a window into as
+// few messages as possible, so a burst of webhooks doesn't flood the
+// channel.
+type coalescer struct {
+	sync.Mutex
+	window  time.Duration
+	send    func(channelID, message string)
+	pending map[string][]string
+}
+
+func newCoalescer(window time.Duration, send func(channelID, message string)) *coalescer {
+	return &coalescer{
+		window:  window,
+		send:    send,
+		pending: make(map[string][]string),
+	}
+}
+
+// add queues message for channelID. The first message queued for a
+// channel starts the window, and everything queued before it ends is
+// sent together.
+func (c *coalescer) add(channelID, message string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, waiting := c.pending[channelID]; !waiting {
+		time.AfterFunc(c.window, func() { c.flushChannel(channelID) })
+	}
+	c.pending[channelID] = append(c.pending[channelID], message)
+}
+
+func (c *coalescer) flushChannel(channelID string) {
+	c.Lock()
+	messages := c.pending[channelID]
+	delete(c.pending, channelID)
+	c.Unlock()
+
+	for _, batch := range joinMessages(messages, messageLimit) {
+		c.send(channelID, batch)
+	}
+}
+
+// flush sends everything that is waiting immediately.
+func (c *coalescer) flush() {
+	c.Lock()
+	channels := make([]string, 0, len(c.pending))
+	for channelID := range c.pending {
+		channels = append(channels, channelID)
+	}
+	c.Unlock()
+
+	for _, channelID := range channels {
+		c.flushChannel(channelID)
+	}
+}
+
+// joinMessages joins messages with newlines into batches no longer
+// than limit. A single message longer than limit is left as is.
+func joinMessages(messages []string, limit int) []string {
+	var batches []string
+	var current strings.Builder
+	for _, m := range messages {
+		if current.Len() > 0 && current.Len()+1+len(m) > limit {
+			batches = append(batches, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(m)
+	}
+	if current.Len() > 0 {
+		batches = append(batches, current.String())
+	}
+	return batches
+}
+
+// SendCoalesced queues message to be sent to channelID. Messages
+// queued for the same channel within CoalesceWindow seconds of each
+// other are combined into a single message.
+func (sp *Spudo) SendCoalesced(channelID, message string) {
+	sp.coalescer.add(channelID, message)
+}
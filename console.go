@@ -0,0 +1,230 @@
+package spudo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anorb/spudo/internal/fake"
+	"github.com/bwmarrin/discordgo"
+)
+
+var errConsoleVoice = errors.New("voice isn't supported in console mode")
+
+// consoleBackend is the Backend used with the -console flag. Messages
+// typed into in are sent to the bot as if they came from a single user
+// in a single channel, and everything the bot does is printed to out.
+type consoleBackend struct {
+	mu        sync.Mutex
+	in        io.Reader
+	out       io.Writer
+	state     *discordgo.State
+	handlers  fake.Handlers
+	webhooks  map[string]*discordgo.Webhook
+	nextID    int
+	userID    string
+	channelID string
+	guildID   string
+}
+
+func newConsoleBackend(in io.Reader, out io.Writer, userID, channelID, guildID string) *consoleBackend {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: "spudo", Username: "spudo", Bot: true}
+	state.GuildAdd(&discordgo.Guild{
+		ID:       guildID,
+		Name:     "console",
+		Channels: []*discordgo.Channel{{ID: channelID, GuildID: guildID, Name: "console", Type: discordgo.ChannelTypeGuildText}},
+	})
+	return &consoleBackend{
+		in:        in,
+		out:       out,
+		state:     state,
+		webhooks:  make(map[string]*discordgo.Webhook),
+		userID:    userID,
+		channelID: channelID,
+		guildID:   guildID,
+	}
+}
+
+func (cb *consoleBackend) newID() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.nextID++
+	return strconv.Itoa(cb.nextID)
+}
+
+// printf writes a line to out, prefixed with the channel it relates to.
+func (cb *consoleBackend) printf(channelID, format string, args ...interface{}) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	fmt.Fprintf(cb.out, "[#%s] %s\n", channelID, fmt.Sprintf(format, args...))
+}
+
+// Open dispatches a Ready event so startup plugins and timed messages
+// run as they would when connecting to Discord.
+func (cb *consoleBackend) Open() error {
+	cb.handlers.Dispatch(fake.Ready(cb.state))
+	return nil
+}
+
+func (cb *consoleBackend) Close() error {
+	return nil
+}
+
+func (cb *consoleBackend) AddHandler(handler interface{}) func() {
+	return cb.handlers.Add(handler)
+}
+
+func (cb *consoleBackend) State() *discordgo.State         { return cb.state }
+func (cb *consoleBackend) HeartbeatLatency() time.Duration { return 0 }
+
+// readInput sends each line read from in to the bot as a message. The
+// returned channel is closed once in has been read to the end.
+func (cb *consoleBackend) readInput() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(cb.in)
+		for scanner.Scan() {
+			cb.receive(scanner.Text())
+		}
+	}()
+	return done
+}
+
+// receive sends content to the bot as a message from the console user.
+func (cb *consoleBackend) receive(content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	cb.handlers.Dispatch(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        cb.newID(),
+		ChannelID: cb.channelID,
+		GuildID:   cb.guildID,
+		Content:   content,
+		Author:    &discordgo.User{ID: cb.userID, Username: cb.userID},
+	}})
+}
+
+func (cb *consoleBackend) message(channelID, content string) *discordgo.Message {
+	return &discordgo.Message{ID: cb.newID(), ChannelID: channelID, Content: content, Author: cb.state.User}
+}
+
+func (cb *consoleBackend) SendMessage(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error) {
+	m := cb.message(channelID, ms.Content)
+	var lines []string
+	if ms.Content != "" {
+		lines = append(lines, ms.Content)
+	}
+	if ms.Embed != nil {
+		lines = append(lines, embedLines(ms.Embed)...)
+		m.Embeds = []*discordgo.MessageEmbed{ms.Embed}
+	}
+	for _, f := range ms.Files {
+		n, err := io.Copy(ioutil.Discard, f.Reader)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("[attachment %s, %d bytes]", f.Name, n))
+	}
+	cb.printf(channelID, "%s (%s): %s", cb.state.User.Username, m.ID, strings.Join(lines, "\n"))
+	return m, nil
+}
+
+func (cb *consoleBackend) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	cb.printf(channelID, "%s edited %s: %s", cb.state.User.Username, messageID, content)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content, Author: cb.state.User}, nil
+}
+
+func (cb *consoleBackend) DeleteMessage(channelID, messageID string) error {
+	cb.printf(channelID, "%s deleted %s", cb.state.User.Username, messageID)
+	return nil
+}
+
+func (cb *consoleBackend) PinMessage(channelID, messageID string) error {
+	cb.printf(channelID, "%s pinned %s", cb.state.User.Username, messageID)
+	return nil
+}
+
+func (cb *consoleBackend) AddReaction(channelID, messageID, emojiID string) error {
+	cb.printf(channelID, "%s reacted to %s with %s", cb.state.User.Username, messageID, emojiID)
+	return nil
+}
+
+func (cb *consoleBackend) Typing(channelID string) error {
+	cb.printf(channelID, "%s is typing...", cb.state.User.Username)
+	return nil
+}
+
+func (cb *consoleBackend) CreateDM(userID string) (*discordgo.Channel, error) {
+	return fake.DMChannel(userID), nil
+}
+
+func (cb *consoleBackend) JoinVoice(guildID, channelID string, mute, deaf bool) (*discordgo.VoiceConnection, error) {
+	return nil, errConsoleVoice
+}
+
+func (cb *consoleBackend) ChannelWebhooks(channelID string) ([]*discordgo.Webhook, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	var webhooks []*discordgo.Webhook
+	for _, wh := range cb.webhooks {
+		if wh.ChannelID == channelID {
+			webhooks = append(webhooks, wh)
+		}
+	}
+	return webhooks, nil
+}
+
+func (cb *consoleBackend) CreateWebhook(channelID, name string) (*discordgo.Webhook, error) {
+	id := cb.newID()
+	wh := &discordgo.Webhook{ID: id, ChannelID: channelID, Name: name, Token: id, User: cb.state.User}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.webhooks[id] = wh
+	return wh, nil
+}
+
+func (cb *consoleBackend) DeleteWebhook(webhookID string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	delete(cb.webhooks, webhookID)
+	return nil
+}
+
+func (cb *consoleBackend) ExecuteWebhook(webhookID, token string, params *discordgo.WebhookParams, am *AllowedMentions) (*discordgo.Message, error) {
+	cb.mu.Lock()
+	wh, ok := cb.webhooks[webhookID]
+	cb.mu.Unlock()
+	if !ok {
+		return nil, errors.New("unknown webhook")
+	}
+
+	username := params.Username
+	if username == "" {
+		username = wh.Name
+	}
+	m := cb.message(wh.ChannelID, params.Content)
+	lines := []string{params.Content}
+	for _, e := range params.Embeds {
+		lines = append(lines, embedLines(e)...)
+	}
+	cb.printf(wh.ChannelID, "%s [webhook] (%s): %s", username, m.ID, strings.Join(lines, "\n"))
+	return m, nil
+}
+
+// embedLines returns the parts of e that are shown in Discord as lines
+// of text, each marked with a bar like Discord's embed border.
+func embedLines(e *discordgo.MessageEmbed) []string {
+	lines := fake.RenderEmbed(e)
+	for i, line := range lines {
+		lines[i] = "| " + line
+	}
+	return lines
+}
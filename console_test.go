@@ -0,0 +1,46 @@
+package spudo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	in := strings.NewReader("!ping\n\nok then\n!embed\n")
+	out := &bytes.Buffer{}
+
+	sp := newSpudo()
+	sp.Config = getDefaultConfig()
+	sp.Config.TypingDelayMS = 0
+	sp.Config.CooldownTimer = 0
+	sp.console = newConsoleBackend(in, out, "user", "channel", "guild")
+	sp.SetBackend(sp.console)
+	sp.AddCommand("ping", "responds with pong", func(author string, args []string) interface{} {
+		return "Pong!"
+	})
+	sp.AddCommand("embed", "responds with an embed", func(author string, args []string) interface{} {
+		return NewEmbed().SetTitle("Title").SetDescription("Description")
+	})
+	sp.AddMessageReaction("ok", []string{"ok"}, []string{"👌"})
+	sp.AddUserReaction("user", []string{"user"}, []string{"👋"})
+
+	if err := sp.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Shutdown()
+	<-sp.console.readInput()
+
+	want := []string{
+		"[#channel] spudo (2): <@user> Pong!",
+		"[#channel] spudo reacted to 1 with 👋",
+		"[#channel] spudo reacted to 3 with 👌",
+		"[#channel] spudo reacted to 3 with 👋",
+		"[#channel] spudo (5): | title: Title\n| description: Description",
+	}
+	for _, w := range want {
+		if !strings.Contains(out.String(), w) {
+			t.Errorf("expected output to contain %q, got:\n%s", w, out.String())
+		}
+	}
+}
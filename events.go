@@ -0,0 +1,242 @@
+package spudo
+
+import (
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	errNoChannel = errors.New("no channel for event")
+	errNoUser    = errors.New("no user for event")
+	errNoMessage = errors.New("no message for event")
+)
+
+// EventContext is passed to event plugins along with the event
+// itself, and to commands added with AddContextCommand. It identifies
+// where the event happened and provides helpers for responding to it.
+type EventContext struct {
+	sp        *Spudo
+	Plugin    string // Name of the plugin handling the event
+	GuildID   string // ID of the guild the event happened in, if any
+	ChannelID string // ID of the channel the event happened in, if any
+	UserID    string // ID of the user that caused the event, if any
+	MessageID string // ID of the message the event is about, if any
+}
+
+// Reply sends message to the channel the event happened in. For
+// member join and leave events this is the guild's system channel.
+// message can be a string, *Embed or *Complex.
+func (ctx *EventContext) Reply(message interface{}) (*discordgo.Message, error) {
+	if ctx.ChannelID == "" {
+		ctx.Logger().Error("Failed to reply to event - no channel for event")
+		return nil, errNoChannel
+	}
+	return ctx.sp.sendTo(ctx.ChannelID, message)
+}
+
+// SendMessage sends message to channelID. message can be a string,
+// *Embed or *Complex.
+func (ctx *EventContext) SendMessage(channelID string, message interface{}) (*discordgo.Message, error) {
+	return ctx.sp.sendTo(channelID, message)
+}
+
+// SendPrivateMessage sends message directly to the user that caused
+// the event. message can be a string or *Embed.
+func (ctx *EventContext) SendPrivateMessage(message interface{}) (*discordgo.Message, error) {
+	if ctx.UserID == "" {
+		ctx.Logger().Error("Failed to send private message - no user for event")
+		return nil, errNoUser
+	}
+	if e, ok := message.(*Embed); ok {
+		message = e.MessageEmbed
+	}
+	return ctx.sp.sendPrivateMessage(ctx.UserID, message)
+}
+
+// Store returns the values stored by the plugin for the guild the
+// event happened in.
+func (ctx *EventContext) Store() *PluginStore {
+	return ctx.sp.PluginStore(ctx.Plugin).Guild(ctx.GuildID)
+}
+
+// Logger returns the bot's Logger with the plugin name attached.
+func (ctx *EventContext) Logger() Logger {
+	return ctx.sp.PluginLogger(ctx.Plugin)
+}
+
+// React adds reactionID to the message the event is about.
+func (ctx *EventContext) React(reactionID string) error {
+	if ctx.ChannelID == "" || ctx.MessageID == "" {
+		ctx.Logger().Error("Failed to react to event - no message for event")
+		return errNoMessage
+	}
+	err := ctx.sp.backend.AddReaction(ctx.ChannelID, ctx.MessageID, reactionID)
+	if err != nil {
+		ctx.Logger().Error("Error adding reaction", "error", err)
+	}
+	return err
+}
+
+// AddMemberJoinPlugin will trigger exec when a member joins a guild.
+func (sp *Spudo) AddMemberJoinPlugin(name string, exec func(ctx *EventContext, member *discordgo.Member)) {
+	sp.addEventPlugin(name, "member join", func(s *discordgo.Session, e *discordgo.GuildMemberAdd) {
+		ctx := sp.newEventContext(name, e.GuildID, sp.systemChannel(e.GuildID), memberUserID(e.Member), "")
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.Member) })
+	})
+}
+
+// AddMemberLeavePlugin will trigger exec when a member leaves or is
+// removed from a guild.
+func (sp *Spudo) AddMemberLeavePlugin(name string, exec func(ctx *EventContext, member *discordgo.Member)) {
+	sp.addEventPlugin(name, "member leave", func(s *discordgo.Session, e *discordgo.GuildMemberRemove) {
+		ctx := sp.newEventContext(name, e.GuildID, sp.systemChannel(e.GuildID), memberUserID(e.Member), "")
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.Member) })
+	})
+}
+
+// AddMessageUpdatePlugin will trigger exec when a message is
+// edited. Edits made by bot users are ignored.
+func (sp *Spudo) AddMessageUpdatePlugin(name string, exec func(ctx *EventContext, message *discordgo.Message)) {
+	sp.addEventPlugin(name, "message update", func(s *discordgo.Session, e *discordgo.MessageUpdate) {
+		var userID string
+		if e.Author != nil {
+			if e.Author.Bot {
+				return
+			}
+			userID = e.Author.ID
+		}
+		ctx := sp.newEventContext(name, e.GuildID, e.ChannelID, userID, e.ID)
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.Message) })
+	})
+}
+
+// AddMessageDeletePlugin will trigger exec when a message is
+// deleted. Discord only sends the IDs of a deleted message, and it is
+// removed from the state cache before plugins run, so only the ID,
+// channel and guild of message are set.
+func (sp *Spudo) AddMessageDeletePlugin(name string, exec func(ctx *EventContext, message *discordgo.Message)) {
+	sp.addEventPlugin(name, "message delete", func(s *discordgo.Session, e *discordgo.MessageDelete) {
+		ctx := sp.newEventContext(name, e.GuildID, e.ChannelID, "", e.ID)
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.Message) })
+	})
+}
+
+// AddReactionAddPlugin will trigger exec when a reaction is added to
+// a message. Reactions added by the bot itself are ignored.
+func (sp *Spudo) AddReactionAddPlugin(name string, exec func(ctx *EventContext, reaction *discordgo.MessageReaction)) {
+	sp.addEventPlugin(name, "reaction add", func(s *discordgo.Session, e *discordgo.MessageReactionAdd) {
+		if sp.isSelf(e.UserID) {
+			return
+		}
+		ctx := sp.newEventContext(name, e.GuildID, e.ChannelID, e.UserID, e.MessageID)
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.MessageReaction) })
+	})
+}
+
+// AddReactionRemovePlugin will trigger exec when a reaction is
+// removed from a message. Reactions removed by the bot itself are
+// ignored.
+func (sp *Spudo) AddReactionRemovePlugin(name string, exec func(ctx *EventContext, reaction *discordgo.MessageReaction)) {
+	sp.addEventPlugin(name, "reaction remove", func(s *discordgo.Session, e *discordgo.MessageReactionRemove) {
+		if sp.isSelf(e.UserID) {
+			return
+		}
+		ctx := sp.newEventContext(name, e.GuildID, e.ChannelID, e.UserID, e.MessageID)
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.MessageReaction) })
+	})
+}
+
+// AddVoiceStateUpdatePlugin will trigger exec when a user joins,
+// leaves or moves between voice channels, or changes their mute or
+// deafen state.
+func (sp *Spudo) AddVoiceStateUpdatePlugin(name string, exec func(ctx *EventContext, state *discordgo.VoiceState)) {
+	sp.addEventPlugin(name, "voice state update", func(s *discordgo.Session, e *discordgo.VoiceStateUpdate) {
+		ctx := sp.newEventContext(name, e.GuildID, e.ChannelID, e.UserID, "")
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.VoiceState) })
+	})
+}
+
+// AddPresenceUpdatePlugin will trigger exec when a user's presence
+// changes in a guild.
+func (sp *Spudo) AddPresenceUpdatePlugin(name string, exec func(ctx *EventContext, presence *discordgo.Presence)) {
+	sp.addEventPlugin(name, "presence update", func(s *discordgo.Session, e *discordgo.PresenceUpdate) {
+		var userID string
+		if e.User != nil {
+			userID = e.User.ID
+		}
+		ctx := sp.newEventContext(name, e.GuildID, "", userID, "")
+		sp.runEventPlugin(ctx, func() { exec(ctx, &e.Presence) })
+	})
+}
+
+// AddChannelCreatePlugin will trigger exec when a channel is
+// created. The context's ChannelID is the new channel.
+func (sp *Spudo) AddChannelCreatePlugin(name string, exec func(ctx *EventContext, channel *discordgo.Channel)) {
+	sp.addEventPlugin(name, "channel create", func(s *discordgo.Session, e *discordgo.ChannelCreate) {
+		ctx := sp.newEventContext(name, e.GuildID, e.ID, "", "")
+		sp.runEventPlugin(ctx, func() { exec(ctx, e.Channel) })
+	})
+}
+
+// addEventPlugin stores handler so it can be added to the session
+// when the bot starts.
+func (sp *Spudo) addEventPlugin(name, event string, handler interface{}) {
+	p := &eventPlugin{
+		Name:    name,
+		Event:   event,
+		Handler: handler,
+	}
+	sp.Lock()
+	sp.eventPlugins = append(sp.eventPlugins, p)
+	// Plugins added after Connect are handled from now on
+	if sp.handlersAdded {
+		sp.backend.AddHandler(handler)
+	}
+	sp.Unlock()
+	sp.logger.Info("Event plugin added", "plugin", name, "event", event)
+}
+
+func (sp *Spudo) newEventContext(plugin, guildID, channelID, userID, messageID string) *EventContext {
+	return &EventContext{
+		sp:        sp,
+		Plugin:    plugin,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		UserID:    userID,
+		MessageID: messageID,
+	}
+}
+
+// runEventPlugin executes exec, recovering and logging a panic so a
+// misbehaving plugin can't take down the bot.
+func (sp *Spudo) runEventPlugin(ctx *EventContext, exec func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctx.Logger().Error("Event plugin panicked", "panic", r)
+		}
+	}()
+	exec()
+}
+
+// systemChannel returns the ID of the guild's system channel if the
+// guild is in the state cache.
+func (sp *Spudo) systemChannel(guildID string) string {
+	g, err := sp.backend.State().Guild(guildID)
+	if err != nil {
+		return ""
+	}
+	return g.SystemChannelID
+}
+
+func (sp *Spudo) isSelf(userID string) bool {
+	state := sp.backend.State()
+	return state.User != nil && state.User.ID == userID
+}
+
+func memberUserID(m *discordgo.Member) string {
+	if m == nil || m.User == nil {
+		return ""
+	}
+	return m.User.ID
+}
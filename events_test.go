@@ -0,0 +1,134 @@
+package spudo
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberJoinPlugin(t *testing.T) {
+	var ctx *EventContext
+	sp, cb, out := newTestBot(t, func(sp *Spudo) {
+		sp.AddMemberJoinPlugin("welcome", func(c *EventContext, member *discordgo.Member) {
+			ctx = c
+			c.Reply("Welcome " + member.User.Username)
+		})
+	})
+	defer sp.Shutdown()
+	g, _ := cb.state.Guild("guild")
+	g.SystemChannelID = "channel"
+
+	cb.handlers.Dispatch(&discordgo.GuildMemberAdd{Member: &discordgo.Member{
+		GuildID: "guild",
+		User:    &discordgo.User{ID: "alice", Username: "alice"},
+	}})
+
+	if ctx == nil {
+		t.Fatal("Expected the plugin to run")
+	}
+	if ctx.Plugin != "welcome" || ctx.GuildID != "guild" || ctx.ChannelID != "channel" || ctx.UserID != "alice" {
+		t.Errorf("Unexpected context %+v", ctx)
+	}
+	if ctx.Store().Scope() != (Scope{Plugin: "welcome", GuildID: "guild"}) {
+		t.Errorf("Expected the store to be scoped to the guild - got %+v", ctx.Store().Scope())
+	}
+	expectOutput(t, out, "[#channel] spudo (1): Welcome alice")
+}
+
+func TestMessagePlugins(t *testing.T) {
+	var updated, deleted []*discordgo.Message
+	sp, cb, _ := newTestBot(t, func(sp *Spudo) {
+		sp.AddMessageUpdatePlugin("edits", func(ctx *EventContext, m *discordgo.Message) {
+			updated = append(updated, m)
+		})
+		sp.AddMessageDeletePlugin("deletes", func(ctx *EventContext, m *discordgo.Message) {
+			if ctx.MessageID != m.ID || ctx.ChannelID != "channel" {
+				t.Errorf("Unexpected context %+v", ctx)
+			}
+			deleted = append(deleted, m)
+		})
+	})
+	defer sp.Shutdown()
+
+	cb.handlers.Dispatch(&discordgo.MessageUpdate{Message: &discordgo.Message{
+		ID: "1", ChannelID: "channel", Content: "edited", Author: &discordgo.User{ID: "alice"},
+	}})
+	cb.handlers.Dispatch(&discordgo.MessageUpdate{Message: &discordgo.Message{
+		ID: "2", ChannelID: "channel", Content: "edited", Author: &discordgo.User{ID: "other-bot", Bot: true},
+	}})
+	cb.handlers.Dispatch(&discordgo.MessageDelete{Message: &discordgo.Message{ID: "1", ChannelID: "channel"}})
+
+	if len(updated) != 1 || updated[0].ID != "1" {
+		t.Errorf("Expected only the edit by a user to be passed on - got %d", len(updated))
+	}
+	if len(deleted) != 1 || deleted[0].ID != "1" {
+		t.Errorf("Expected the deleted message to be passed on - got %d", len(deleted))
+	}
+}
+
+func TestReactionPluginIgnoresSelf(t *testing.T) {
+	var users []string
+	sp, cb, _ := newTestBot(t, func(sp *Spudo) {
+		sp.AddReactionAddPlugin("reactions", func(ctx *EventContext, r *discordgo.MessageReaction) {
+			users = append(users, ctx.UserID)
+		})
+	})
+	defer sp.Shutdown()
+
+	for _, userID := range []string{"alice", "spudo"} {
+		cb.handlers.Dispatch(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+			UserID: userID, MessageID: "1", ChannelID: "channel", Emoji: discordgo.Emoji{Name: "👍"},
+		}})
+	}
+
+	if len(users) != 1 || users[0] != "alice" {
+		t.Errorf("Expected only alice's reaction to be passed on - got %v", users)
+	}
+}
+
+func TestEventPluginPanic(t *testing.T) {
+	ran := false
+	sp, cb, _ := newTestBot(t, func(sp *Spudo) {
+		sp.AddChannelCreatePlugin("broken", func(ctx *EventContext, c *discordgo.Channel) {
+			panic("oops")
+		})
+		sp.AddChannelCreatePlugin("working", func(ctx *EventContext, c *discordgo.Channel) {
+			ran = ctx.ChannelID == "new"
+		})
+	})
+	defer sp.Shutdown()
+
+	cb.handlers.Dispatch(&discordgo.ChannelCreate{Channel: &discordgo.Channel{ID: "new", GuildID: "guild"}})
+
+	if !ran {
+		t.Error("Expected plugins after a panicking one to still run")
+	}
+}
+
+func TestEventContextWithoutChannel(t *testing.T) {
+	ctx := newSpudo().newEventContext("presence", "guild", "", "", "")
+	if _, err := ctx.Reply("hi"); err != errNoChannel {
+		t.Errorf("Expected errNoChannel - got %v", err)
+	}
+	if _, err := ctx.SendPrivateMessage("hi"); err != errNoUser {
+		t.Errorf("Expected errNoUser - got %v", err)
+	}
+	if err := ctx.React("👍"); err != errNoMessage {
+		t.Errorf("Expected errNoMessage - got %v", err)
+	}
+}
+
+func TestEventPluginAddedAfterConnect(t *testing.T) {
+	sp, cb, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+
+	var channels []string
+	sp.AddChannelCreatePlugin("late", func(ctx *EventContext, c *discordgo.Channel) {
+		channels = append(channels, c.ID)
+	})
+	cb.handlers.Dispatch(&discordgo.ChannelCreate{Channel: &discordgo.Channel{ID: "new", GuildID: "guild"}})
+
+	if len(channels) != 1 || channels[0] != "new" {
+		t.Errorf("Expected a plugin added after Connect to be run once - got %v", channels)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/anorb/spudo"
 )
 
@@ -10,11 +12,16 @@ func main() {
 	bot.AddCommand("complex", "image attachment (rather than imbed)", complexAttachment)
 	bot.AddCommand("hello", "says hello + whatever argument follows", hello)
 	bot.AddCommand("ping", "responds with pong", ping)
+	bot.AddCommand("echo", "repeats whatever follows without pinging anyone", echo)
 
 	bot.AddStartupPlugin("welcome message", func() {
 		bot.SendMessage("789654132546789", "I'm back!")
 	})
 
+	bot.AddShutdownPlugin("goodbye message", func() {
+		bot.SendMessage("789654132546789", "Shutting down!")
+	})
+
 	bot.AddTimedMessage("five seconds", "0,5,10,15,20,25,30,35,40,45,50,55 * * * * *", []string{"354846132188644643"}, timer)
 
 	bot.AddMessageReaction("reacts to ok", []string{"ok"}, []string{"👌"})
@@ -36,6 +43,11 @@ func hello(author string, args []string) interface{} {
 	return "Hello <@" + author + ">"
 }
 
+func echo(author string, args []string) interface{} {
+	// Arguments are escaped so they can't be used to ping anyone
+	return spudo.EscapeMentions(strings.Join(args, " "))
+}
+
 func ping(author string, args []string) interface{} {
 	return "Pong!"
 }
@@ -0,0 +1,210 @@
+package gitwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anorb/spudo"
+)
+
+type githubUser struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+	HTMLURL   string `json:"html_url"`
+}
+
+type githubRepository struct {
+	FullName string `json:"full_name"`
+	HTMLURL  string `json:"html_url"`
+}
+
+type githubCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+	Author  struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+type githubPayload struct {
+	Action     string           `json:"action"`
+	Repository githubRepository `json:"repository"`
+	Sender     githubUser       `json:"sender"`
+
+	// push
+	Ref     string         `json:"ref"`
+	Compare string         `json:"compare"`
+	Deleted bool           `json:"deleted"`
+	Commits []githubCommit `json:"commits"`
+
+	// pull_request
+	PullRequest *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+	} `json:"pull_request"`
+
+	// issues
+	Issue *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"issue"`
+
+	// release
+	Release *struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"release"`
+
+	// workflow_run
+	WorkflowRun *struct {
+		Name       string `json:"name"`
+		HeadBranch string `json:"head_branch"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+	} `json:"workflow_run"`
+
+	// status
+	SHA         string `json:"sha"`
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
+
+// parseGitHub formats a GitHub webhook payload. kind is the value of
+// the X-GitHub-Event header.
+func parseGitHub(kind string, body []byte) (*event, error) {
+	var p githubPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	e := spudo.NewEmbed().SetAuthor(p.Sender.Login, p.Sender.AvatarURL, p.Sender.HTMLURL)
+	ev := &event{Repository: p.Repository.FullName, Embed: e}
+	repo := p.Repository.FullName
+
+	switch kind {
+	case "push":
+		ev.Type = EventPush
+		branch := strings.TrimPrefix(strings.TrimPrefix(p.Ref, "refs/heads/"), "refs/tags/")
+		if p.Deleted {
+			e.SetTitle(fmt.Sprintf("[%s] %s deleted", repo, branch)).SetColor(colorClosed)
+			return ev, nil
+		}
+		if len(p.Commits) == 0 {
+			return nil, errUnsupportedEvent
+		}
+		var lines []string
+		for _, c := range p.Commits {
+			lines = append(lines, fmt.Sprintf("[`%s`](%s) %s - %s", shortSHA(c.ID), c.URL, firstLine(c.Message), c.Author.Name))
+		}
+		e.SetTitle(fmt.Sprintf("[%s:%s] %d new %s", repo, branch, len(p.Commits), plural(len(p.Commits), "commit"))).
+			SetURL(p.Compare).
+			SetDescription(strings.Join(lines, "\n")).
+			SetColor(colorPush)
+
+	case "pull_request":
+		if p.PullRequest == nil {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventPullRequest
+		action, color := p.Action, colorOpen
+		switch {
+		case p.Action == "closed" && p.PullRequest.Merged:
+			action, color = "merged", colorMerged
+		case p.Action == "closed":
+			color = colorClosed
+		case p.Action == "ready_for_review":
+			action = "ready for review"
+		case p.Action != "opened" && p.Action != "reopened":
+			return nil, errUnsupportedEvent
+		}
+		e.SetTitle(fmt.Sprintf("[%s] Pull request %s: #%d %s", repo, action, p.PullRequest.Number, p.PullRequest.Title)).
+			SetURL(p.PullRequest.HTMLURL).
+			SetColor(color)
+		if p.Action == "opened" {
+			e.SetDescription(p.PullRequest.Body)
+		}
+
+	case "issues":
+		if p.Issue == nil {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventIssue
+		color := colorOpen
+		switch p.Action {
+		case "opened", "reopened":
+		case "closed":
+			color = colorClosed
+		default:
+			return nil, errUnsupportedEvent
+		}
+		e.SetTitle(fmt.Sprintf("[%s] Issue %s: #%d %s", repo, p.Action, p.Issue.Number, p.Issue.Title)).
+			SetURL(p.Issue.HTMLURL).
+			SetColor(color)
+		if p.Action == "opened" {
+			e.SetDescription(p.Issue.Body)
+		}
+
+	case "release":
+		if p.Release == nil || p.Action != "published" {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventRelease
+		name := p.Release.Name
+		if name == "" {
+			name = p.Release.TagName
+		}
+		e.SetTitle(fmt.Sprintf("[%s] New release published: %s", repo, name)).
+			SetURL(p.Release.HTMLURL).
+			SetDescription(p.Release.Body).
+			SetColor(colorRelease)
+
+	case "workflow_run":
+		if p.WorkflowRun == nil || p.Action != "completed" {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventCI
+		run := p.WorkflowRun
+		e.SetTitle(fmt.Sprintf("[%s:%s] %s %s", repo, run.HeadBranch, run.Name, run.Conclusion)).
+			SetURL(run.HTMLURL).
+			SetColor(ciColor(run.Conclusion == "success"))
+
+	case "status":
+		if p.State == "pending" {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventCI
+		e.SetTitle(fmt.Sprintf("[%s] %s %s for %s", repo, p.Context, p.State, shortSHA(p.SHA))).
+			SetURL(p.TargetURL).
+			SetDescription(p.Description).
+			SetColor(ciColor(p.State == "success"))
+
+	default:
+		return nil, errUnsupportedEvent
+	}
+	return ev, nil
+}
+
+func ciColor(success bool) int {
+	if success {
+		return colorSuccess
+	}
+	return colorFailure
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,187 @@
+package gitwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anorb/spudo"
+)
+
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+type gitlabUser struct {
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitlabCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+	Author  struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+type gitlabPayload struct {
+	Project gitlabProject `json:"project"`
+	User    gitlabUser    `json:"user"`
+
+	// Push Hook
+	Before       string         `json:"before"`
+	After        string         `json:"after"`
+	Ref          string         `json:"ref"`
+	UserName     string         `json:"user_name"`
+	UserUsername string         `json:"user_username"`
+	UserAvatar   string         `json:"user_avatar"`
+	Commits      []gitlabCommit `json:"commits"`
+
+	// Merge Request Hook, Issue Hook and Pipeline Hook
+	ObjectAttributes *struct {
+		ID          int    `json:"id"`
+		IID         int    `json:"iid"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		URL         string `json:"url"`
+		Action      string `json:"action"`
+		Ref         string `json:"ref"`
+		Status      string `json:"status"`
+	} `json:"object_attributes"`
+
+	// Release Hook
+	Action      string `json:"action"`
+	Name        string `json:"name"`
+	Tag         string `json:"tag"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+// gitlabActions maps GitLab's merge request and issue actions to the
+// past tense used in titles. Other actions are ignored.
+var gitlabActions = map[string]string{
+	"open":   "opened",
+	"reopen": "reopened",
+	"close":  "closed",
+	"merge":  "merged",
+}
+
+// parseGitLab formats a GitLab webhook payload. kind is the value of
+// the X-Gitlab-Event header.
+func parseGitLab(kind string, body []byte) (*event, error) {
+	var p gitlabPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	e := spudo.NewEmbed()
+	ev := &event{Repository: p.Project.PathWithNamespace, Embed: e}
+	repo := p.Project.PathWithNamespace
+	if p.User.Username != "" {
+		e.SetAuthor(p.User.Username, p.User.AvatarURL)
+	}
+
+	switch kind {
+	case "Push Hook", "Tag Push Hook":
+		ev.Type = EventPush
+		e.SetAuthor(p.UserUsername, p.UserAvatar)
+		branch := strings.TrimPrefix(strings.TrimPrefix(p.Ref, "refs/heads/"), "refs/tags/")
+		if strings.Trim(p.After, "0") == "" {
+			e.SetTitle(fmt.Sprintf("[%s] %s deleted", repo, branch)).SetColor(colorClosed)
+			return ev, nil
+		}
+		if len(p.Commits) == 0 {
+			return nil, errUnsupportedEvent
+		}
+		var lines []string
+		for _, c := range p.Commits {
+			lines = append(lines, fmt.Sprintf("[`%s`](%s) %s - %s", shortSHA(c.ID), c.URL, firstLine(c.Message), c.Author.Name))
+		}
+		e.SetTitle(fmt.Sprintf("[%s:%s] %d new %s", repo, branch, len(p.Commits), plural(len(p.Commits), "commit"))).
+			SetURL(p.Project.WebURL + "/-/compare/" + p.Before + "..." + p.After).
+			SetDescription(strings.Join(lines, "\n")).
+			SetColor(colorPush)
+
+	case "Merge Request Hook":
+		attrs := p.ObjectAttributes
+		action, ok := "", false
+		if attrs != nil {
+			action, ok = gitlabActions[attrs.Action]
+		}
+		if !ok {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventPullRequest
+		color := colorOpen
+		switch action {
+		case "merged":
+			color = colorMerged
+		case "closed":
+			color = colorClosed
+		}
+		e.SetTitle(fmt.Sprintf("[%s] Merge request %s: !%d %s", repo, action, attrs.IID, attrs.Title)).
+			SetURL(attrs.URL).
+			SetColor(color)
+		if action == "opened" {
+			e.SetDescription(attrs.Description)
+		}
+
+	case "Issue Hook":
+		attrs := p.ObjectAttributes
+		action, ok := "", false
+		if attrs != nil {
+			action, ok = gitlabActions[attrs.Action]
+		}
+		if !ok || action == "merged" {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventIssue
+		color := colorOpen
+		if action == "closed" {
+			color = colorClosed
+		}
+		e.SetTitle(fmt.Sprintf("[%s] Issue %s: #%d %s", repo, action, attrs.IID, attrs.Title)).
+			SetURL(attrs.URL).
+			SetColor(color)
+		if action == "opened" {
+			e.SetDescription(attrs.Description)
+		}
+
+	case "Release Hook":
+		if p.Action != "create" {
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventRelease
+		name := p.Name
+		if name == "" {
+			name = p.Tag
+		}
+		e.SetTitle(fmt.Sprintf("[%s] New release published: %s", repo, name)).
+			SetURL(p.URL).
+			SetDescription(p.Description).
+			SetColor(colorRelease)
+
+	case "Pipeline Hook":
+		attrs := p.ObjectAttributes
+		if attrs == nil {
+			return nil, errUnsupportedEvent
+		}
+		switch attrs.Status {
+		case "success", "failed", "canceled":
+		default:
+			return nil, errUnsupportedEvent
+		}
+		ev.Type = EventCI
+		e.SetTitle(fmt.Sprintf("[%s:%s] Pipeline #%d %s", repo, attrs.Ref, attrs.ID, attrs.Status)).
+			SetURL(fmt.Sprintf("%s/-/pipelines/%d", p.Project.WebURL, attrs.ID)).
+			SetColor(ciColor(attrs.Status == "success"))
+
+	default:
+		return nil, errUnsupportedEvent
+	}
+	return ev, nil
+}
@@ -0,0 +1,192 @@
+// Package gitwebhook posts GitHub and GitLab webhook events to Discord
+// channels as embeds. It adds REST routes to a spudo bot that verify
+// the webhook signatures, format push, pull/merge request, issue,
+// release and CI events, and send them to the channels configured for
+// the repository.
+package gitwebhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/anorb/spudo"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Event types that repositories can subscribe to.
+const (
+	EventPush        = "push"
+	EventPullRequest = "pull_request"
+	EventIssue       = "issue"
+	EventRelease     = "release"
+	EventCI          = "ci"
+)
+
+// Embed colors used for the different kinds of events.
+const (
+	colorPush    = 0x0366d6
+	colorOpen    = 0x2ea44f
+	colorClosed  = 0xcb2431
+	colorMerged  = 0x6f42c1
+	colorRelease = 0xf9c513
+	colorSuccess = 0x2ea44f
+	colorFailure = 0xcb2431
+)
+
+// Maximum size of a webhook payload.
+const maxPayloadSize = 25 << 20
+
+var errUnsupportedEvent = errors.New("unsupported event")
+
+// Config contains the options for the webhook routes.
+type Config struct {
+	Route        string       // Base route, GitHub is served at Route/github and GitLab at Route/gitlab
+	GitHubSecret string       // Name of the secret in the bot's RESTSecrets used to verify GitHub signatures
+	GitLabSecret string       // Name of the secret in the bot's RESTSecrets compared against GitLab's X-Gitlab-Token
+	Repositories []Repository // Where events for each repository are sent
+}
+
+// Repository routes events for a repository to channels.
+type Repository struct {
+	Name     string   // Full name of the repository such as owner/repo, or * to match any repository
+	Channels []string // IDs of channels events are sent to
+	Events   []string // Event types to send, all of them if empty
+}
+
+// Sender sends an embed to a channel. *spudo.Spudo satisfies it once
+// the bot has started.
+type Sender interface {
+	SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+}
+
+// event is a webhook payload formatted for Discord.
+type event struct {
+	Repository string
+	Type       string
+	Embed      *spudo.Embed
+}
+
+// parser turns a webhook payload into an event. kind is the value of
+// the provider's event header.
+type parser func(kind string, body []byte) (*event, error)
+
+// Register adds the GitHub and GitLab webhook routes to bot. A
+// provider's route is only added if its secret is set, so unsigned
+// webhooks are never accepted.
+func Register(bot *spudo.Spudo, config Config) {
+	route := strings.TrimSuffix(config.Route, "/")
+	logger := bot.PluginLogger("gitwebhook")
+	if config.GitHubSecret != "" {
+		bot.AddRESTRoute(route+"/github",
+			handler(bot, logger, config.Repositories, "X-GitHub-Event", parseGitHub),
+			spudo.WithMethods(http.MethodPost),
+			spudo.RequireGitHubSignature(config.GitHubSecret))
+	}
+	if config.GitLabSecret != "" {
+		bot.AddRESTRoute(route+"/gitlab",
+			handler(bot, logger, config.Repositories, "X-Gitlab-Event", parseGitLab),
+			spudo.WithMethods(http.MethodPost),
+			spudo.RequireSharedSecret(config.GitLabSecret, "X-Gitlab-Token"))
+	}
+}
+
+// handler returns a REST handler that parses payloads with parse and
+// sends the resulting embed to every channel subscribed to it.
+// Unsupported events are acknowledged so the provider doesn't treat
+// them as failed deliveries, while an event that couldn't be sent to
+// any channel is reported as one so the provider can retry it.
+func handler(sender Sender, logger spudo.Logger, repos []Repository, eventHeader string, parse parser) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		ev, err := parse(r.Header.Get(eventHeader), body)
+		if err == errUnsupportedEvent {
+			writeJSON(w, http.StatusAccepted, map[string]interface{}{"ignored": true})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		channels := channelsFor(repos, ev)
+		sent := 0
+		failed := []string{}
+		for _, chanID := range channels {
+			if _, err := sender.SendEmbed(chanID, ev.Embed.MessageEmbed); err != nil {
+				logger.Error("Failed to send webhook event", "repository", ev.Repository, "event", ev.Type, "channel", chanID, "error", err)
+				failed = append(failed, chanID)
+				continue
+			}
+			sent++
+		}
+		if len(channels) > 0 && sent == 0 {
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+				"error":  "failed to send event to any channel",
+				"sent":   0,
+				"failed": failed,
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sent": sent, "failed": failed})
+	}
+}
+
+// channelsFor returns the channels subscribed to ev, without
+// duplicates.
+func channelsFor(repos []Repository, ev *event) []string {
+	seen := make(map[string]bool)
+	var channels []string
+	for _, repo := range repos {
+		if repo.Name != "*" && !strings.EqualFold(repo.Name, ev.Repository) {
+			continue
+		}
+		if len(repo.Events) > 0 && !contains(repo.Events, ev.Type) {
+			continue
+		}
+		for _, c := range repo.Channels {
+			if !seen[c] {
+				seen[c] = true
+				channels = append(channels, c)
+			}
+		}
+	}
+	return channels
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// firstLine returns the first line of a commit message.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,139 @@
+package gitwebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/anorb/spudo"
+	"github.com/bwmarrin/discordgo"
+)
+
+var discardLogger = spudo.NewStdLogger(log.New(ioutil.Discard, "", 0), spudo.LevelError)
+
+func loadFixture(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Error reading fixture - %s", err.Error())
+	}
+	return body
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		fixture string
+		parse   parser
+		kind    string
+		repo    string
+		typ     string
+		title   string
+		color   int
+	}{
+		{"github_push.json", parseGitHub, "push", "anorb/spudo", EventPush, "[anorb/spudo:master] 1 new commit", colorPush},
+		{"github_pull_request.json", parseGitHub, "pull_request", "anorb/spudo", EventPullRequest, "[anorb/spudo] Pull request merged: #12 Add REST API", colorMerged},
+		{"github_issues.json", parseGitHub, "issues", "anorb/spudo", EventIssue, "[anorb/spudo] Issue opened: #7 Audio stops after first track", colorOpen},
+		{"github_release.json", parseGitHub, "release", "anorb/spudo", EventRelease, "[anorb/spudo] New release published: v0.2.0", colorRelease},
+		{"github_workflow_run.json", parseGitHub, "workflow_run", "anorb/spudo", EventCI, "[anorb/spudo:master] Build failure", colorFailure},
+		{"gitlab_push.json", parseGitLab, "Push Hook", "mike/diaspora", EventPush, "[mike/diaspora:main] 2 new commits", colorPush},
+		{"gitlab_merge_request.json", parseGitLab, "Merge Request Hook", "gitlabhq/gitlab-test", EventPullRequest, "[gitlabhq/gitlab-test] Merge request opened: !1 MS-Viewport", colorOpen},
+		{"gitlab_pipeline.json", parseGitLab, "Pipeline Hook", "gitlab-org/gitlab-test", EventCI, "[gitlab-org/gitlab-test:master] Pipeline #31 success", colorSuccess},
+	}
+	for _, tt := range tests {
+		ev, err := tt.parse(tt.kind, loadFixture(t, tt.fixture))
+		if err != nil {
+			t.Errorf("%s - unexpected error %s", tt.fixture, err.Error())
+			continue
+		}
+		if ev.Repository != tt.repo || ev.Type != tt.typ {
+			t.Errorf("%s - got %s %s, want %s %s", tt.fixture, ev.Repository, ev.Type, tt.repo, tt.typ)
+		}
+		if ev.Embed.Title != tt.title {
+			t.Errorf("%s - got title %q, want %q", tt.fixture, ev.Embed.Title, tt.title)
+		}
+		if ev.Embed.Color != tt.color {
+			t.Errorf("%s - got color %#x, want %#x", tt.fixture, ev.Embed.Color, tt.color)
+		}
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	if _, err := parseGitHub("star", loadFixture(t, "github_push.json")); err != errUnsupportedEvent {
+		t.Errorf("Expected unsupported event error - got %v", err)
+	}
+}
+
+type recordingSender struct {
+	sent map[string]*discordgo.MessageEmbed
+	fail map[string]bool // Channels sending to fails for
+}
+
+func (rs *recordingSender) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if rs.fail[channelID] {
+		return nil, errors.New("missing access")
+	}
+	rs.sent[channelID] = embed
+	return &discordgo.Message{ChannelID: channelID}, nil
+}
+
+func TestHandlerRouting(t *testing.T) {
+	sender := &recordingSender{sent: make(map[string]*discordgo.MessageEmbed)}
+	repos := []Repository{
+		{Name: "anorb/spudo", Channels: []string{"commits"}, Events: []string{EventPush}},
+		{Name: "anorb/spudo", Channels: []string{"releases"}, Events: []string{EventRelease}},
+		{Name: "*", Channels: []string{"everything"}},
+	}
+	h := handler(sender, discardLogger, repos, "X-GitHub-Event", parseGitHub)
+
+	r := httptest.NewRequest("POST", "/github", bytes.NewReader(loadFixture(t, "github_push.json")))
+	r.Header.Set("X-GitHub-Event", "push")
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", w.Code)
+	}
+	if len(sender.sent) != 2 || sender.sent["commits"] == nil || sender.sent["everything"] == nil {
+		t.Errorf("Expected push to be sent to commits and everything - got %v", sender.sent)
+	}
+}
+
+func TestHandlerSendFailures(t *testing.T) {
+	repos := []Repository{{Name: "*", Channels: []string{"one", "two"}}}
+	tests := []struct {
+		fail   map[string]bool
+		status int
+	}{
+		{map[string]bool{}, http.StatusOK},
+		{map[string]bool{"one": true}, http.StatusOK},
+		{map[string]bool{"one": true, "two": true}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		sender := &recordingSender{sent: make(map[string]*discordgo.MessageEmbed), fail: tt.fail}
+		h := handler(sender, discardLogger, repos, "X-GitHub-Event", parseGitHub)
+
+		r := httptest.NewRequest("POST", "/github", bytes.NewReader(loadFixture(t, "github_push.json")))
+		r.Header.Set("X-GitHub-Event", "push")
+		w := httptest.NewRecorder()
+		h(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d when sending to %v fails - got %d", tt.status, tt.fail, w.Code)
+		}
+		var body struct {
+			Sent   int      `json:"sent"`
+			Failed []string `json:"failed"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Sent != 2-len(tt.fail) || len(body.Failed) != len(tt.fail) {
+			t.Errorf("Expected %d sent and %d failed - got %+v", 2-len(tt.fail), len(tt.fail), body)
+		}
+	}
+}
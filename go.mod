@@ -9,4 +9,5 @@ require (
 	github.com/jonas747/ogg v0.0.0-20161220051205-b4f6f4cf3757 // indirect
 	github.com/robfig/cron/v3 v3.0.0
 	github.com/rylio/ytdl v0.6.2
+	modernc.org/sqlite v1.14.8
 )
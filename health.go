@@ -0,0 +1,106 @@
+package spudo
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// How long a plugin health check can take before it is considered
+// failed.
+const healthCheckTimeout = 5 * time.Second
+
+var errHealthCheckTimeout = errors.New("timed out")
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// AddHealthCheck will add a check to the /readyz endpoint. The bot is
+// reported as not ready while check returns an error.
+func (sp *Spudo) AddHealthCheck(name string, check func() error) {
+	p := &healthCheck{
+		Name:  name,
+		Check: check,
+	}
+	sp.healthChecks = append(sp.healthChecks, p)
+	sp.logger.Info("Health check added", "plugin", name)
+}
+
+// addHealthRoutes adds the /healthz and /readyz endpoints to the REST
+// API.
+func (sp *Spudo) addHealthRoutes() {
+	sp.AddRESTRoute("healthz", sp.serveHealthz, WithMethods(http.MethodGet))
+	sp.AddRESTRoute("readyz", sp.serveReadyz, WithMethods(http.MethodGet))
+}
+
+// serveHealthz reports that the process is alive. If it can respond at
+// all, it is.
+func (sp *Spudo) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// serveReadyz reports whether the gateway is connected, the Ready
+// event has been received, the REST API is listening and every plugin
+// health check passes. It responds with 503 if any of them fail.
+func (sp *Spudo) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	sp.Lock()
+	connected := sp.connected
+	ready := sp.startupDone
+	restUp := sp.restListening
+	sp.Unlock()
+
+	resp := readinessResponse{Status: "ready", Checks: make(map[string]string)}
+	fail := func(name, reason string) {
+		resp.Status = "not ready"
+		resp.Checks[name] = reason
+	}
+
+	resp.Checks["gateway"] = "ok"
+	if !connected {
+		fail("gateway", "disconnected")
+	}
+	resp.Checks["ready"] = "ok"
+	if !ready {
+		fail("ready", "ready event not received")
+	}
+	resp.Checks["rest"] = "ok"
+	if !restUp {
+		fail("rest", "not listening")
+	}
+	for _, hc := range sp.healthChecks {
+		if err := runHealthCheck(hc.Check); err != nil {
+			fail(hc.Name, err.Error())
+		} else {
+			resp.Checks[hc.Name] = "ok"
+		}
+	}
+
+	status := http.StatusOK
+	if resp.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// runHealthCheck runs check, giving up after healthCheckTimeout so a
+// hung dependency can't hang the probe.
+func runHealthCheck(check func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- errors.New("health check panicked")
+			}
+		}()
+		result <- check()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(healthCheckTimeout):
+		return errHealthCheckTimeout
+	}
+}
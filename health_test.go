@@ -0,0 +1,36 @@
+package spudo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	bot := newSpudo()
+	var pluginErr error
+	bot.AddHealthCheck("database", func() error { return pluginErr })
+
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		bot.serveReadyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready before connecting - got %d", code)
+	}
+
+	bot.connected = true
+	bot.startupDone = true
+	bot.restListening = true
+	if code := readyz(); code != http.StatusOK {
+		t.Errorf("Expected ready once connected - got %d", code)
+	}
+
+	pluginErr = errors.New("connection refused")
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready when a health check fails - got %d", code)
+	}
+}
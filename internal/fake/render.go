@@ -0,0 +1,49 @@
+// Package fake holds what spudo's console backend and the spudotest
+// package share in faking a Discord session.
+package fake
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var lineEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// RenderEmbed describes the parts of e that are shown in Discord, one
+// line for each as "part: value".
+func RenderEmbed(e *discordgo.MessageEmbed) []string {
+	var lines []string
+	add := func(name, value string) {
+		if value != "" {
+			lines = append(lines, name+": "+EscapeLine(value))
+		}
+	}
+	if e.Author != nil {
+		add("author", e.Author.Name)
+	}
+	add("title", e.Title)
+	add("url", e.URL)
+	add("description", e.Description)
+	for _, f := range e.Fields {
+		add("field "+f.Name, f.Value)
+	}
+	if e.Image != nil {
+		add("image", e.Image.URL)
+	}
+	if e.Thumbnail != nil {
+		add("thumbnail", e.Thumbnail.URL)
+	}
+	if e.Footer != nil {
+		add("footer", e.Footer.Text)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "empty")
+	}
+	return lines
+}
+
+// EscapeLine writes s on a single line.
+func EscapeLine(s string) string {
+	return lineEscaper.Replace(s)
+}
@@ -0,0 +1,77 @@
+package fake
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Handlers holds discordgo event handlers, functions that take a
+// *discordgo.Session and a pointer to an event struct, and passes
+// events to them. The zero value has no handlers.
+type Handlers struct {
+	mu       sync.Mutex
+	handlers []*handler
+}
+
+type handler struct {
+	fn interface{}
+}
+
+// Add registers fn, returning a function that removes it again.
+func (hs *Handlers) Add(fn interface{}) func() {
+	h := &handler{fn: fn}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.handlers = append(hs.handlers, h)
+	return func() {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		for i, other := range hs.handlers {
+			if other == h {
+				hs.handlers = append(hs.handlers[:i:i], hs.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Dispatch calls every handler that takes an event of the same type as
+// event, in the order they were added and with a nil session. It
+// returns once the handlers have returned.
+func (hs *Handlers) Dispatch(event interface{}) {
+	hs.mu.Lock()
+	handlers := append([]*handler{}, hs.handlers...)
+	hs.mu.Unlock()
+
+	ev := reflect.ValueOf(event)
+	for _, h := range handlers {
+		hv := reflect.ValueOf(h.fn)
+		t := hv.Type()
+		if t.Kind() != reflect.Func || t.NumIn() != 2 {
+			continue
+		}
+		if in := t.In(1); in == ev.Type() || (in.Kind() == reflect.Interface && ev.Type().Implements(in)) {
+			hv.Call([]reflect.Value{reflect.Zero(t.In(0)), ev})
+		}
+	}
+}
+
+// Ready returns the Ready event for connecting with state, listing the
+// bot's user and the guilds in it.
+func Ready(state *discordgo.State) *discordgo.Ready {
+	state.RLock()
+	defer state.RUnlock()
+	return &discordgo.Ready{User: state.User, Guilds: append([]*discordgo.Guild{}, state.Guilds...)}
+}
+
+// DMChannel returns the private channel for userID, with the ID "dm-"
+// followed by the user's ID.
+func DMChannel(userID string) *discordgo.Channel {
+	return &discordgo.Channel{
+		ID:         "dm-" + userID,
+		Type:       discordgo.ChannelTypeDM,
+		Recipients: []*discordgo.User{{ID: userID}},
+	}
+}
@@ -0,0 +1,44 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHandlers(t *testing.T) {
+	var hs Handlers
+	var got []string
+	hs.Add(func(s *discordgo.Session, m *discordgo.MessageCreate) { got = append(got, "create "+m.Content) })
+	remove := hs.Add(func(s *discordgo.Session, m *discordgo.MessageCreate) { got = append(got, "removed") })
+	hs.Add(func(s *discordgo.Session, r *discordgo.Ready) { got = append(got, "ready") })
+	hs.Add(func(s *discordgo.Session, e interface{}) { got = append(got, "any") })
+	hs.Add("not a handler")
+
+	hs.Dispatch(&discordgo.MessageCreate{Message: &discordgo.Message{Content: "one"}})
+	remove()
+	remove()
+	hs.Dispatch(&discordgo.MessageCreate{Message: &discordgo.Message{Content: "two"}})
+	hs.Dispatch(&discordgo.Ready{})
+
+	want := []string{"create one", "removed", "any", "create two", "any", "ready", "any"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %q - got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %q - got %q", want, got)
+		}
+	}
+}
+
+func TestReady(t *testing.T) {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: "bot"}
+	state.GuildAdd(&discordgo.Guild{ID: "guild"})
+
+	r := Ready(state)
+	if r.User.ID != "bot" || len(r.Guilds) != 1 || r.Guilds[0].ID != "guild" {
+		t.Errorf("Unexpected ready event: %+v", r)
+	}
+}
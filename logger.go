@@ -1,31 +1,236 @@
 package spudo
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-type spudoLogger struct {
-	infologger  *log.Logger
-	errorlogger *log.Logger
+// LogLevel is the minimum severity a Logger will write.
+type LogLevel int
+
+// Log levels in increasing order of severity.
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lowercase name of the level.
+func (lvl LogLevel) String() string {
+	switch lvl {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(lvl))
+}
+
+// ParseLogLevel returns the LogLevel named by s. It accepts debug,
+// info, warn and error in any case.
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// Logger is used by spudo and its plugins for logging. fields are
+// alternating key and value pairs that are attached to the message.
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+	// With returns a Logger that attaches fields to every message.
+	With(fields ...interface{}) Logger
+}
+
+// stdLogger is a Logger that writes key=value formatted lines through
+// a *log.Logger.
+type stdLogger struct {
+	l      *log.Logger
+	level  LogLevel
+	fields []interface{}
+}
+
+// NewStdLogger returns a Logger that writes messages at or above
+// level to l, formatted as the message followed by key=value fields.
+func NewStdLogger(l *log.Logger, level LogLevel) Logger {
+	return &stdLogger{l: l, level: level}
+}
+
+func (sl *stdLogger) Debug(msg string, fields ...interface{}) { sl.log(LevelDebug, msg, fields) }
+func (sl *stdLogger) Info(msg string, fields ...interface{})  { sl.log(LevelInfo, msg, fields) }
+func (sl *stdLogger) Warn(msg string, fields ...interface{})  { sl.log(LevelWarn, msg, fields) }
+func (sl *stdLogger) Error(msg string, fields ...interface{}) { sl.log(LevelError, msg, fields) }
+
+func (sl *stdLogger) With(fields ...interface{}) Logger {
+	return &stdLogger{l: sl.l, level: sl.level, fields: appendFields(sl.fields, fields)}
+}
+
+func (sl *stdLogger) log(lvl LogLevel, msg string, fields []interface{}) {
+	if lvl < sl.level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(lvl.String()))
+	b.WriteString(": ")
+	b.WriteString(msg)
+	forEachField(appendFields(sl.fields, fields), func(k string, v interface{}) {
+		b.WriteString(" ")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(formatTextValue(v))
+	})
+	sl.l.Print(b.String())
+}
+
+// jsonLogger is a Logger that writes one JSON object per line.
+type jsonLogger struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  LogLevel
+	fields []interface{}
+}
+
+// NewJSONLogger returns a Logger that writes messages at or above
+// level to w as JSON objects, one per line, with time, level and msg
+// keys alongside the fields.
+func NewJSONLogger(w io.Writer, level LogLevel) Logger {
+	return &jsonLogger{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (jl *jsonLogger) Debug(msg string, fields ...interface{}) { jl.log(LevelDebug, msg, fields) }
+func (jl *jsonLogger) Info(msg string, fields ...interface{})  { jl.log(LevelInfo, msg, fields) }
+func (jl *jsonLogger) Warn(msg string, fields ...interface{})  { jl.log(LevelWarn, msg, fields) }
+func (jl *jsonLogger) Error(msg string, fields ...interface{}) { jl.log(LevelError, msg, fields) }
+
+func (jl *jsonLogger) With(fields ...interface{}) Logger {
+	return &jsonLogger{mu: jl.mu, w: jl.w, level: jl.level, fields: appendFields(jl.fields, fields)}
+}
+
+func (jl *jsonLogger) log(lvl LogLevel, msg string, fields []interface{}) {
+	if lvl < jl.level {
+		return
+	}
+	entry := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": lvl.String(),
+		"msg":   msg,
+	}
+	forEachField(appendFields(jl.fields, fields), func(k string, v interface{}) {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	})
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": lvl.String(),
+			"msg":   msg,
+			"error": "failed to encode log fields: " + err.Error(),
+		})
+	}
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.w.Write(append(line, '\n'))
+}
+
+// newLogger returns the default Logger, which writes info and above
+// to stdout.
+func newLogger() Logger {
+	return NewStdLogger(log.New(os.Stdout, "", log.Ldate|log.Ltime), LevelInfo)
+}
+
+// newConfigLogger returns a Logger writing to w using the format and
+// level set in config.
+func newConfigLogger(config Config, w io.Writer) (Logger, error) {
+	level, err := ParseLogLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(config.LogFormat) {
+	case "", "text":
+		return NewStdLogger(log.New(w, "", log.Ldate|log.Ltime), level), nil
+	case "json":
+		return NewJSONLogger(w, level), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", config.LogFormat)
+}
+
+// Logger returns the Logger used by the bot.
+func (sp *Spudo) Logger() Logger {
+	return sp.logger
+}
+
+// PluginLogger returns the Logger used by the bot with the plugin
+// name attached to every message.
+func (sp *Spudo) PluginLogger(name string) Logger {
+	return sp.logger.With("plugin", name)
+}
+
+// SetLogger replaces the Logger used by the bot. It should be called
+// before Start.
+func (sp *Spudo) SetLogger(l Logger) {
+	sp.logger = l
+	if sp.session != nil {
+		sp.session.logger = l
+	}
 }
 
-func newLogger() (l *spudoLogger) {
-	l = new(spudoLogger)
-	l.infologger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
-	l.errorlogger = log.New(os.Stdout, "ERR: ", log.Ldate|log.Ltime|log.Lshortfile)
-	return
+// fatal logs msg as an error and exits.
+func (sp *Spudo) fatal(msg string, fields ...interface{}) {
+	sp.logger.Error(msg, fields...)
+	os.Exit(1)
 }
 
-func (l spudoLogger) info(msg string, extra ...interface{}) {
-	l.infologger.Print(msg, fmt.Sprintln(extra...))
+func appendFields(base, extra []interface{}) []interface{} {
+	if len(extra) == 0 {
+		return base
+	}
+	fields := make([]interface{}, 0, len(base)+len(extra))
+	fields = append(fields, base...)
+	return append(fields, extra...)
 }
 
-func (l spudoLogger) error(msg string, extra ...interface{}) {
-	l.errorlogger.Print(msg, fmt.Sprintln(extra...))
+// forEachField calls fn for each key and value pair in fields. A
+// trailing key without a value is given the value "(MISSING)".
+func forEachField(fields []interface{}, fn func(k string, v interface{})) {
+	for i := 0; i < len(fields); i += 2 {
+		k := fmt.Sprint(fields[i])
+		if i+1 >= len(fields) {
+			fn(k, "(MISSING)")
+			return
+		}
+		fn(k, fields[i+1])
+	}
 }
 
-func (l spudoLogger) fatal(msg string, extra ...interface{}) {
-	l.errorlogger.Fatal(msg, fmt.Sprintln(extra...))
+func formatTextValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
 }
@@ -0,0 +1,52 @@
+package spudo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStdLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LevelWarn)
+	l.Info("hidden")
+	l.With("plugin", "test").Warn("shown", "error", errors.New("bad thing"))
+
+	if got, want := buf.String(), "WARN: shown plugin=test error=\"bad thing\"\n"; got != want {
+		t.Errorf("Unexpected log output - got %q, want %q", got, want)
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONLogger(&buf, LevelDebug).With("plugin", "test")
+	l.Debug("message", "count", 2, "error", errors.New("bad thing"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Error decoding log line - %s", err.Error())
+	}
+	for k, want := range map[string]interface{}{
+		"level":  "debug",
+		"msg":    "message",
+		"plugin": "test",
+		"count":  float64(2),
+		"error":  "bad thing",
+	} {
+		if entry[k] != want {
+			t.Errorf("Unexpected value for %s - got %v, want %v", k, entry[k], want)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if lvl, err := ParseLogLevel("WARN"); err != nil || lvl != LevelWarn {
+		t.Errorf("Expected warn level - got %v, %v", lvl, err)
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil || !strings.Contains(err.Error(), "verbose") {
+		t.Errorf("Expected error for unknown level - got %v", err)
+	}
+}
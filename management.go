@@ -0,0 +1,155 @@
+package spudo
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Maximum size of a multipart message upload held in memory, the rest
+// is stored in temporary files.
+const maxUploadMemory = 32 << 20
+
+// messageRequest is the body accepted by the send message endpoint.
+type messageRequest struct {
+	Content string                  `json:"content"`
+	Embed   *discordgo.MessageEmbed `json:"embed"`
+}
+
+type commandInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	BuiltIn     bool   `json:"builtin"`
+}
+
+type statusResponse struct {
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Guilds        int     `json:"guilds"`
+	LatencyMS     float64 `json:"latency_ms"`
+	AudioSessions int     `json:"audio_sessions"`
+	SendQueue     int     `json:"send_queue"`
+}
+
+// addManagementRoutes adds the built-in management endpoints to the
+// REST API. They all require an API key from RESTAPIKeys, so they are
+// skipped if none are configured.
+func (sp *Spudo) addManagementRoutes() {
+	if len(sp.Config.RESTAPIKeys) == 0 {
+		sp.logger.Warn("Management API not added - RESTAPIKeys is empty")
+		return
+	}
+	sp.AddRESTRoute("api/channels/{id}/messages", sp.apiSendMessage, WithMethods(http.MethodPost), RequireAPIKey())
+	sp.AddRESTRoute("api/commands", sp.apiCommands, WithMethods(http.MethodGet), RequireAPIKey())
+	sp.AddRESTRoute("api/status", sp.apiStatus, WithMethods(http.MethodGet), RequireAPIKey())
+	sp.AddRESTRoute("api/timers/{name}/trigger", sp.apiTriggerTimer, WithMethods(http.MethodPost), RequireAPIKey())
+}
+
+// apiSendMessage sends a message to the channel in the path. The body
+// is either a JSON messageRequest, or a multipart form with content
+// and embed (as JSON) fields and any number of file fields.
+func (sp *Spudo) apiSendMessage(w http.ResponseWriter, r *http.Request) {
+	ms := &discordgo.MessageSend{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid form: "+err.Error())
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		ms.Content = r.FormValue("content")
+		if embed := r.FormValue("embed"); embed != "" {
+			if err := json.Unmarshal([]byte(embed), &ms.Embed); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid embed: "+err.Error())
+				return
+			}
+		}
+		for _, fh := range r.MultipartForm.File["file"] {
+			f, err := fh.Open()
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid file: "+err.Error())
+				return
+			}
+			defer f.Close()
+			ms.Files = append(ms.Files, &discordgo.File{
+				Name:        fh.Filename,
+				ContentType: fh.Header.Get("Content-Type"),
+				Reader:      f,
+			})
+		}
+	} else {
+		var req messageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		ms.Content = req.Content
+		ms.Embed = req.Embed
+	}
+
+	if ms.Content == "" && ms.Embed == nil && len(ms.Files) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "message has no content, embed or files")
+		return
+	}
+
+	msg, err := sp.sendMessageSend(PathParam(r, "id"), ms, sp.mentions)
+	if err != nil {
+		sp.logger.Error("Failed to send management API message", "channel", PathParam(r, "id"), "error", err)
+		writeJSONError(w, http.StatusBadGateway, "failed to send message: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": msg.ID, "channel_id": msg.ChannelID})
+}
+
+// apiCommands lists every registered command, sorted by name.
+func (sp *Spudo) apiCommands(w http.ResponseWriter, r *http.Request) {
+	commands := make([]commandInfo, 0, len(sp.commands)+len(sp.spudoCommands))
+	for _, c := range sp.commands {
+		commands = append(commands, commandInfo{Name: c.Name, Description: c.Description})
+	}
+	for _, c := range sp.spudoCommands {
+		commands = append(commands, commandInfo{Name: c.Name, Description: c.Description, BuiltIn: true})
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	writeJSON(w, http.StatusOK, commands)
+}
+
+// apiStatus reports the uptime, guild count, gateway latency and
+// number of audio sessions.
+func (sp *Spudo) apiStatus(w http.ResponseWriter, r *http.Request) {
+	sp.Lock()
+	audioSessions := len(sp.audioSessions)
+	sp.Unlock()
+
+	state := sp.backend.State()
+	state.RLock()
+	guilds := len(state.Guilds)
+	state.RUnlock()
+
+	writeJSON(w, http.StatusOK, statusResponse{
+		UptimeSeconds: time.Since(sp.startTime).Seconds(),
+		Guilds:        guilds,
+		LatencyMS:     float64(sp.backend.HeartbeatLatency()) / float64(time.Millisecond),
+		AudioSessions: audioSessions,
+		SendQueue:     sp.queue.length(),
+	})
+}
+
+// apiTriggerTimer runs the timed message named in the path
+// immediately, outside of its schedule.
+func (sp *Spudo) apiTriggerTimer(w http.ResponseWriter, r *http.Request) {
+	name := PathParam(r, "name")
+	for _, p := range sp.timedMessages {
+		if p.Name == name {
+			sp.runTimedMessage(p)
+			writeJSON(w, http.StatusOK, map[string]string{"triggered": name})
+			return
+		}
+	}
+	writeJSONError(w, http.StatusNotFound, "no timed message named "+name)
+}
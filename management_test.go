@@ -0,0 +1,230 @@
+package spudo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// failingBackend is a console backend that can't send messages.
+type failingBackend struct {
+	*consoleBackend
+}
+
+func (fb failingBackend) SendMessage(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error) {
+	return nil, errors.New("missing access")
+}
+
+// addManagementTestRoutes adds the management routes to a connected
+// bot, and returns a function that serves a request to them with the
+// API key.
+func addManagementTestRoutes(sp *Spudo) func(r *http.Request) *httptest.ResponseRecorder {
+	// Set after connecting so the REST API isn't listening
+	sp.Config.RESTEnabled = true
+	sp.Config.RESTAPIKeys = []string{"key"}
+	sp.addManagementRoutes()
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		r.Header.Set("X-API-Key", "key")
+		w := httptest.NewRecorder()
+		sp.restRouter.ServeHTTP(w, r)
+		return w
+	}
+	return serve
+}
+
+func TestManagementRoutesNeedAPIKeys(t *testing.T) {
+	sp := newSpudo()
+	sp.Config.RESTEnabled = true
+	sp.addManagementRoutes()
+
+	for _, path := range []string{"/api/commands", "/api/status"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-API-Key", "")
+		w := httptest.NewRecorder()
+		sp.restRouter.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s not to be added without RESTAPIKeys - got status %d", path, w.Code)
+		}
+	}
+}
+
+func TestAPISendMessage(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"content", `{"content": "hello"}`, http.StatusCreated},
+		{"embed", `{"embed": {"title": "Deploy"}}`, http.StatusCreated},
+		{"invalid JSON", `{"content": `, http.StatusBadRequest},
+		{"empty message", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/channels/channel/messages", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+		if w := serve(r); w.Code != tt.code {
+			t.Errorf("%s - got status %d, want %d: %s", tt.name, w.Code, tt.code, w.Body.String())
+		}
+	}
+	r := httptest.NewRequest("GET", "/api/channels/channel/messages", nil)
+	if w := serve(r); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to be rejected - got status %d", w.Code)
+	}
+
+	expectOutput(t, out,
+		"[#channel] spudo (1): hello",
+		"[#channel] spudo (2): | title: Deploy",
+	)
+}
+
+func TestAPISendMessageLongContent(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+
+	content := strings.Repeat("word ", 500)
+	r := httptest.NewRequest("POST", "/api/channels/channel/messages", strings.NewReader(`{"content": "`+content+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := serve(r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var sent map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &sent); err != nil || sent["id"] != "1" {
+		t.Errorf("Expected the first message to be returned - got %v (%v)", sent, err)
+	}
+	if n := strings.Count(out.String(), "[#channel] spudo ("); n != 2 {
+		t.Errorf("Expected content over the length limit to be split in two - got %d messages", n)
+	}
+}
+
+func TestAPISendMessageMultipart(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("content", "report")
+	fw, _ := mw.CreateFormFile("file", "report.txt")
+	fw.Write([]byte("12345"))
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/channels/channel/messages", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := serve(r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["id"] != "1" || resp["channel_id"] != "channel" {
+		t.Errorf("Expected the sent message in the response - got %v", resp)
+	}
+	expectOutput(t, out, "[#channel] spudo (1): report", "[attachment report.txt, 5 bytes]")
+
+	body.Reset()
+	mw = multipart.NewWriter(body)
+	mw.WriteField("embed", "{not json")
+	mw.Close()
+	r = httptest.NewRequest("POST", "/api/channels/channel/messages", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if w := serve(r); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid embed to be rejected - got status %d", w.Code)
+	}
+}
+
+func TestAPISendMessageFailure(t *testing.T) {
+	sp, _, _ := newTestBot(t, func(sp *Spudo) {
+		sp.Config.SendAttempts = 1
+		sp.SetBackend(failingBackend{sp.backend.(*consoleBackend)})
+	})
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+
+	w := serve(httptest.NewRequest("POST", "/api/channels/channel/messages", strings.NewReader(`{"content": "hello"}`)))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 when sending fails - got status %d", w.Code)
+	}
+}
+
+func TestAPICommands(t *testing.T) {
+	sp, _, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+	respond := func(author string, args []string) interface{} { return nil }
+	sp.AddCommand("zebra", "last", respond)
+	sp.AddCommand("apple", "first", respond)
+
+	w := serve(httptest.NewRequest("GET", "/api/commands", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", w.Code)
+	}
+	var commands []commandInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &commands); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range commands {
+		if !c.BuiltIn {
+			names = append(names, c.Name)
+		}
+	}
+	if strings.Join(names, ",") != "apple,zebra" {
+		t.Errorf("Expected commands sorted by name - got %v", names)
+	}
+	if len(commands) != len(sp.spudoCommands)+2 {
+		t.Errorf("Expected built-in commands to be listed - got %d commands", len(commands))
+	}
+}
+
+func TestAPIStatus(t *testing.T) {
+	sp, _, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+
+	w := serve(httptest.NewRequest("GET", "/api/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", w.Code)
+	}
+	var status statusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Guilds != 1 || status.UptimeSeconds <= 0 || status.SendQueue != 0 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	r := httptest.NewRequest("GET", "/api/status", nil)
+	w = httptest.NewRecorder()
+	sp.restRouter.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a request without a key to be rejected - got status %d", w.Code)
+	}
+}
+
+func TestAPITriggerTimer(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	serve := addManagementTestRoutes(sp)
+	sp.AddTimedMessage("reminder", "0 9 * * *", []string{"channel"}, func() interface{} { return "Stand up!" })
+
+	if w := serve(httptest.NewRequest("POST", "/api/timers/reminder/trigger", nil)); w.Code != http.StatusOK {
+		t.Errorf("Unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(httptest.NewRequest("POST", "/api/timers/missing/trigger", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown timer - got status %d", w.Code)
+	}
+	expectOutput(t, out, "[#channel] spudo (1): Stand up!")
+}
@@ -0,0 +1,108 @@
+package spudo
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Types of mentions that can be allowed with AllowedMentions.Parse.
+const (
+	MentionUsers    = "users"
+	MentionRoles    = "roles"
+	MentionEveryone = "everyone"
+)
+
+// markdownEscaper escapes the characters Discord uses for formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`, "|", `\|`, ">", `\>`,
+)
+
+// AllowedMentions controls which mentions in a message notify anyone.
+// Mentions that aren't allowed are still shown, but nobody is pinged.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`           // Types of mentions that are allowed: MentionUsers, MentionRoles or MentionEveryone
+	Users []string `json:"users,omitempty"` // IDs of users that can be mentioned, if Parse doesn't include MentionUsers
+	Roles []string `json:"roles,omitempty"` // IDs of roles that can be mentioned, if Parse doesn't include MentionRoles
+}
+
+// NoMentions returns AllowedMentions that don't let a message ping
+// anyone.
+func NoMentions() *AllowedMentions {
+	return &AllowedMentions{}
+}
+
+// AllowUsers returns AllowedMentions that only let a message ping the
+// users with the given IDs.
+func AllowUsers(userIDs ...string) *AllowedMentions {
+	return &AllowedMentions{Users: userIDs}
+}
+
+// normalize returns am in the form Discord expects, where an empty
+// Parse has to be sent as an empty list rather than left out.
+func (am *AllowedMentions) normalize() *AllowedMentions {
+	if am == nil || am.Parse != nil {
+		return am
+	}
+	n := *am
+	n.Parse = []string{}
+	return &n
+}
+
+// withUser returns am with userID allowed to be mentioned as well.
+func (am *AllowedMentions) withUser(userID string) *AllowedMentions {
+	if am == nil {
+		return nil
+	}
+	for _, p := range am.Parse {
+		if p == MentionUsers {
+			return am
+		}
+	}
+	n := *am
+	n.Users = append(append([]string{}, am.Users...), userID)
+	return &n
+}
+
+// MentionResponse is a response sent with its own AllowedMentions
+// rather than the default from the Config.
+type MentionResponse struct {
+	Message         interface{}      // The response, a string, *Embed or *Complex
+	AllowedMentions *AllowedMentions // Mentions allowed in the response
+}
+
+// WithMentions wraps message so it is sent with am instead of the
+// default AllowedMentions. It can be returned from commands, timed
+// messages and passed to EventContext's Reply and SendMessage.
+func WithMentions(message interface{}, am *AllowedMentions) *MentionResponse {
+	return &MentionResponse{Message: message, AllowedMentions: am}
+}
+
+// SendWithMentions sends message to channelID, only allowing the
+// mentions in am to ping anyone. message can be a string, *Embed or
+// *Complex.
+func (sp *Spudo) SendWithMentions(channelID string, message interface{}, am *AllowedMentions) (*discordgo.Message, error) {
+	switch v := message.(type) {
+	case string:
+		return sp.sendText(channelID, v, am)
+	case *Embed:
+		return sp.sendEmbed(channelID, v.MessageEmbed, am)
+	case *Complex:
+		defer v.file.Close()
+		return sp.sendComplex(channelID, v.MessageSend, am)
+	}
+	return nil, errUnsupportedMessage
+}
+
+// EscapeMentions stops any user, role, @everyone or @here mentions in
+// s from working, so user input can be repeated safely. The text
+// looks the same when displayed.
+func EscapeMentions(s string) string {
+	return strings.Replace(s, "@", "@\u200b", -1)
+}
+
+// EscapeMarkdown escapes the characters Discord uses for formatting,
+// so s is displayed exactly as written.
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,62 @@
+package spudo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestEscapeMentions(t *testing.T) {
+	for _, s := range []string{"@everyone", "@here", "<@1234>", "<@!1234>", "<@&5678>"} {
+		if got := EscapeMentions(s); strings.Contains(got, "@e") || strings.Contains(got, "@h") || strings.Contains(got, "<@1") || strings.Contains(got, "<@!") || strings.Contains(got, "<@&") {
+			t.Errorf("Expected %q to be escaped - got %q", s, got)
+		}
+	}
+	if got := EscapeMarkdown("**bold** `code`"); got != "\\*\\*bold\\*\\* \\`code\\`" {
+		t.Errorf("Unexpected escaped markdown %q", got)
+	}
+}
+
+func TestAllowedMentionsPayload(t *testing.T) {
+	payload := &messagePayload{
+		MessageSend:     &discordgo.MessageSend{Content: "@everyone"},
+		AllowedMentions: NoMentions().normalize(),
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"content":"@everyone"`) || !strings.Contains(string(b), `"allowed_mentions":{"parse":[]}`) {
+		t.Errorf("Unexpected payload %s", b)
+	}
+
+	am := &AllowedMentions{Parse: []string{MentionRoles}}
+	if got := am.withUser("1234"); len(got.Users) != 1 || len(am.Users) != 0 {
+		t.Errorf("Expected author to be added to a copy - got %+v", got)
+	}
+	am = &AllowedMentions{Parse: []string{MentionUsers}}
+	if got := am.withUser("1234"); len(got.Users) != 0 {
+		t.Errorf("Expected no users list when all users are allowed - got %+v", got)
+	}
+}
+
+func TestMultipartMessage(t *testing.T) {
+	ms := &discordgo.MessageSend{
+		Content: "report",
+		Files:   []*discordgo.File{{Name: "report.txt", Reader: strings.NewReader("contents")}},
+	}
+	body, contentType, err := multipartMessage(&messagePayload{MessageSend: ms, AllowedMentions: NoMentions().normalize()}, ms.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(contentType, "multipart/form-data") {
+		t.Errorf("Unexpected content type %q", contentType)
+	}
+	for _, expected := range []string{`name="payload_json"`, `"allowed_mentions":{"parse":[]}`, `filename="report.txt"`, "contents"} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected body to contain %q", expected)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package spudo
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// labelEscaper escapes label values for the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Buckets for the command latency histogram, in seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricVec is a counter or histogram with a set of label values for
+// each series.
+type metricVec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newCounterVec(name, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: "counter", labels: labels, series: make(map[string]*series)}
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, typ: "histogram", labels: labels, buckets: buckets, series: make(map[string]*series)}
+}
+
+func (mv *metricVec) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := mv.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues}
+		if mv.typ == "histogram" {
+			s.counts = make([]uint64, len(mv.buckets))
+		}
+		mv.series[key] = s
+	}
+	return s
+}
+
+func (mv *metricVec) inc(labelValues ...string) {
+	mv.get(labelValues).value++
+}
+
+func (mv *metricVec) observe(v float64, labelValues ...string) {
+	s := mv.get(labelValues)
+	for i, b := range mv.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// write writes every series in the Prometheus text format, sorted by
+// label values so the output is stable.
+func (mv *metricVec) write(w io.Writer) {
+	writeHeader(w, mv.name, mv.help, mv.typ)
+	keys := make([]string, 0, len(mv.series))
+	for k := range mv.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := mv.series[k]
+		if mv.typ != "histogram" {
+			writeSample(w, mv.name, mv.labels, s.labelValues, s.value)
+			continue
+		}
+		labels := append(append([]string{}, mv.labels...), "le")
+		for i, b := range mv.buckets {
+			values := append(append([]string{}, s.labelValues...), formatFloat(b))
+			writeSample(w, mv.name+"_bucket", labels, values, float64(s.counts[i]))
+		}
+		values := append(append([]string{}, s.labelValues...), "+Inf")
+		writeSample(w, mv.name+"_bucket", labels, values, float64(s.count))
+		writeSample(w, mv.name+"_sum", mv.labels, s.labelValues, s.sum)
+		writeSample(w, mv.name+"_count", mv.labels, s.labelValues, float64(s.count))
+	}
+}
+
+// metrics holds the counters exposed on the /metrics endpoint. Gauges
+// are read from the bot when the endpoint is scraped.
+type metrics struct {
+	sync.Mutex
+	commandInvocations *metricVec
+	commandLatency     *metricVec
+	cooldownRejections *metricVec
+	unknownCommands    *metricVec
+	messagesSent       *metricVec
+	messagesFailed     *metricVec
+	messageRetries     *metricVec
+	deadLetters        *metricVec
+	timedMessageRuns   *metricVec
+	timedMessageFails  *metricVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		commandInvocations: newCounterVec("spudo_command_invocations_total", "Commands handled, by command and outcome.", "command", "outcome"),
+		commandLatency:     newHistogramVec("spudo_command_duration_seconds", "Time taken to handle a command and send its response.", latencyBuckets, "command", "outcome"),
+		cooldownRejections: newCounterVec("spudo_cooldown_rejections_total", "Commands rejected because the user was on cooldown."),
+		unknownCommands:    newCounterVec("spudo_unknown_commands_total", "Commands that did not match a registered command."),
+		messagesSent:       newCounterVec("spudo_messages_sent_total", "Messages sent, by type.", "type"),
+		messagesFailed:     newCounterVec("spudo_messages_failed_total", "Messages that failed to send, by type.", "type"),
+		messageRetries:     newCounterVec("spudo_message_retries_total", "Messages sent again after a transient error, by type.", "type"),
+		deadLetters:        newCounterVec("spudo_messages_dead_lettered_total", "Messages given up on after every attempt failed, by type.", "type"),
+		timedMessageRuns:   newCounterVec("spudo_timed_message_runs_total", "Timed message executions, by timed message.", "timer"),
+		timedMessageFails:  newCounterVec("spudo_timed_message_failures_total", "Timed message executions that failed, by timed message.", "timer"),
+	}
+	// Counters without labels are reported as zero before anything is counted
+	m.cooldownRejections.get(nil)
+	m.unknownCommands.get(nil)
+	return m
+}
+
+// observeCommand records a handled command. Cooldowns and unknown
+// commands are counted separately, since their names come from user
+// input and would create a series for anything a user types.
+func (m *metrics) observeCommand(command, outcome string, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	switch outcome {
+	case outcomeCooldown:
+		m.cooldownRejections.inc()
+	case outcomeUnknown:
+		m.unknownCommands.inc()
+	default:
+		m.commandInvocations.inc(command, outcome)
+		m.commandLatency.observe(latency.Seconds(), command, outcome)
+	}
+}
+
+// observeSend records the result of sending a message of type kind.
+func (m *metrics) observeSend(kind string, err error) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	if err != nil {
+		m.messagesFailed.inc(kind)
+		return
+	}
+	m.messagesSent.inc(kind)
+}
+
+// observeRetry records a message of type kind being sent again.
+func (m *metrics) observeRetry(kind string) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.messageRetries.inc(kind)
+}
+
+// observeDeadLetter records a message of type kind being given up on.
+func (m *metrics) observeDeadLetter(kind string) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.deadLetters.inc(kind)
+}
+
+// observeTimedMessage records a run of the timed message name.
+func (m *metrics) observeTimedMessage(name string, failed bool) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.timedMessageRuns.inc(name)
+	if failed {
+		m.timedMessageFails.inc(name)
+	}
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, mv := range []*metricVec{
+		m.commandInvocations,
+		m.commandLatency,
+		m.cooldownRejections,
+		m.unknownCommands,
+		m.messagesSent,
+		m.messagesFailed,
+		m.messageRetries,
+		m.deadLetters,
+		m.timedMessageRuns,
+		m.timedMessageFails,
+	} {
+		mv.write(w)
+	}
+}
+
+// addMetricsRoute adds the /metrics endpoint to the REST API.
+func (sp *Spudo) addMetricsRoute() {
+	opts := []RESTRouteOption{WithMethods(http.MethodGet)}
+	if sp.Config.MetricsRequireAPIKey {
+		opts = append(opts, RequireAPIKey())
+	}
+	sp.AddRESTRoute("metrics", sp.serveMetrics, opts...)
+}
+
+func (sp *Spudo) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	sp.metrics.write(w)
+	sp.writeGauges(w)
+}
+
+// writeGauges writes the metrics that reflect the current state of
+// the bot rather than being counted as things happen.
+func (sp *Spudo) writeGauges(w io.Writer) {
+	writeHeader(w, "spudo_gateway_latency_seconds", "Time between the last gateway heartbeat and its acknowledgement.", "gauge")
+	var latency time.Duration
+	if sp.session != nil {
+		latency = sp.backend.HeartbeatLatency()
+	}
+	writeSample(w, "spudo_gateway_latency_seconds", nil, nil, latency.Seconds())
+
+	sp.Lock()
+	sessions := make(map[string]*spAudio, len(sp.audioSessions))
+	for guildID, as := range sp.audioSessions {
+		sessions[guildID] = as
+	}
+	sp.Unlock()
+
+	writeHeader(w, "spudo_send_queue_length", "Messages waiting to be sent.", "gauge")
+	var queued int
+	if sp.session != nil {
+		queued = sp.queue.length()
+	}
+	writeSample(w, "spudo_send_queue_length", nil, nil, float64(queued))
+
+	writeHeader(w, "spudo_audio_sessions", "Active audio sessions.", "gauge")
+	writeSample(w, "spudo_audio_sessions", nil, nil, float64(len(sessions)))
+
+	writeHeader(w, "spudo_audio_queue_length", "Media waiting to be played, by guild.", "gauge")
+	guildIDs := make([]string, 0, len(sessions))
+	for guildID := range sessions {
+		guildIDs = append(guildIDs, guildID)
+	}
+	sort.Strings(guildIDs)
+	for _, guildID := range guildIDs {
+		writeSample(w, "spudo_audio_queue_length", []string{"guild"}, []string{guildID}, float64(sessions[guildID].queue.length()))
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, v float64) {
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+		return
+	}
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = l + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,39 @@
+package spudo
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	bot := newSpudo()
+	bot.metrics.observeCommand("ping", outcomeOK, 30*time.Millisecond)
+	bot.metrics.observeCommand("pnig", outcomeUnknown, time.Millisecond)
+	bot.metrics.observeSend("text", nil)
+	bot.metrics.observeSend("embed", errors.New("failed"))
+	bot.metrics.observeTimedMessage("news", true)
+
+	w := httptest.NewRecorder()
+	bot.serveMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`spudo_command_invocations_total{command="ping",outcome="ok"} 1`,
+		`spudo_command_duration_seconds_bucket{command="ping",outcome="ok",le="0.025"} 0`,
+		`spudo_command_duration_seconds_bucket{command="ping",outcome="ok",le="0.05"} 1`,
+		`spudo_command_duration_seconds_count{command="ping",outcome="ok"} 1`,
+		`spudo_unknown_commands_total 1`,
+		`spudo_cooldown_rejections_total 0`,
+		`spudo_messages_sent_total{type="text"} 1`,
+		`spudo_messages_failed_total{type="embed"} 1`,
+		`spudo_timed_message_failures_total{timer="news"} 1`,
+		`spudo_audio_sessions 0`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
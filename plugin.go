@@ -1,15 +1,26 @@
 package spudo
 
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
 type command struct {
-	Name            string                                         // Name of the command
-	Exec            func(author string, args []string) interface{} // Function that will be executed when command is used
-	Description     string                                         // Description of command for a help command to use
-	PrivateResponse bool                                           // Indicates whether or not the command will yield a private message response
+	Name            string                                             // Name of the command
+	Exec            func(author string, args []string) interface{}     // Function that will be executed when command is used
+	ExecContext     func(ctx *EventContext, args []string) interface{} // Executed instead of Exec for commands added with AddContextCommand
+	Description     string                                             // Description of command for a help command to use
+	PrivateResponse bool                                               // Indicates whether or not the command will yield a private message response
+	NoTyping        bool                                               // Indicates whether the typing indicator should not be shown while the command runs
+}
+
+type lifecyclePlugin struct {
+	Name string // Name of the plugin
+	Exec func() // Function that will be executed when the lifecycle event occurs
 }
 
-type startupPlugin struct {
-	Name string
-	Exec func()
+type guildPlugin struct {
+	Name string                       // Name of the plugin
+	Exec func(guild *discordgo.Guild) // Function that will be executed with the guild that was joined or left
 }
 
 type timedMessage struct {
@@ -17,6 +28,7 @@ type timedMessage struct {
 	Channels   []string           // IDs of channels the message should be sent in
 	CronString string             // Cron-style string to determine when the Exec function is executed
 	Exec       func() interface{} // Function that will be executed
+	Webhook    *WebhookIdentity   // Identity to send the message as through a webhook, nil to send as the bot
 }
 
 type userReaction struct {
@@ -36,3 +48,19 @@ type spudoCommand struct {
 	Exec        func(author, channel string, args ...string) interface{} // Function that will be executed when command is used
 	Description string                                                   // Description of command for a help command to use
 }
+
+type eventPlugin struct {
+	Name    string      // Name of the event plugin
+	Event   string      // Name of the Discord event the plugin handles
+	Handler interface{} // discordgo event handler that will be added to the session
+}
+
+type deadLetterPlugin struct {
+	Name string               // Name of the dead letter plugin
+	Exec func(dl *DeadLetter) // Function that will be executed
+}
+
+type healthCheck struct {
+	Name  string       // Name of the health check
+	Check func() error // Function that returns an error when the plugin isn't healthy
+}
@@ -0,0 +1,225 @@
+package spudo
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Delay before the first retry of a failed send, doubled for each
+// attempt after that.
+const sendRetryDelay = time.Second
+
+// DeadLetter is a message that could not be sent after every attempt.
+type DeadLetter struct {
+	ChannelID string      // ID of the channel the message was for
+	Message   interface{} // The message, either a string, *discordgo.MessageEmbed, *discordgo.MessageSend or *discordgo.WebhookParams
+	Attempts  int         // Number of times sending was attempted
+	Err       error       // Error from the last attempt
+}
+
+// sendQueue sends messages to each channel one at a time, in the order
+// they were queued, retrying those that fail with a transient error.
+type sendQueue struct {
+	sync.Mutex
+	maxAttempts int
+	baseDelay   time.Duration
+	logger      Logger
+	metrics     *metrics
+	deadLetter  func(dl *DeadLetter)
+	pending     map[string][]*sendJob
+	workers     sync.WaitGroup
+	stopped     bool
+	quit        chan struct{}
+}
+
+type sendJob struct {
+	kind    string
+	message interface{}
+	send    func() (*discordgo.Message, error)
+	retry   bool
+	done    chan sendResult
+}
+
+type sendResult struct {
+	m   *discordgo.Message
+	err error
+}
+
+func newSendQueue(maxAttempts int, logger Logger, m *metrics, deadLetter func(dl *DeadLetter)) *sendQueue {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &sendQueue{
+		maxAttempts: maxAttempts,
+		baseDelay:   sendRetryDelay,
+		logger:      logger,
+		metrics:     m,
+		deadLetter:  deadLetter,
+		pending:     make(map[string][]*sendJob),
+		quit:        make(chan struct{}),
+	}
+}
+
+// do queues send for channelID and waits for it to be sent. kind and
+// message describe what is being sent for metrics and dead letters.
+// If retry is false send is only attempted once, for messages that
+// can't be sent again such as those with files. Once the queue is
+// stopped send is attempted once straight away.
+func (q *sendQueue) do(channelID, kind string, message interface{}, retry bool, send func() (*discordgo.Message, error)) (*discordgo.Message, error) {
+	if q == nil {
+		return send()
+	}
+
+	job := &sendJob{kind: kind, message: message, send: send, retry: retry, done: make(chan sendResult, 1)}
+	q.Lock()
+	if q.stopped {
+		q.Unlock()
+		return send()
+	}
+	jobs, running := q.pending[channelID]
+	q.pending[channelID] = append(jobs, job)
+	if !running {
+		q.workers.Add(1)
+	}
+	q.Unlock()
+	if !running {
+		go q.run(channelID)
+	}
+
+	res := <-job.done
+	return res.m, res.err
+}
+
+// stop gives up retrying failed sends and waits for the messages
+// already queued to be attempted.
+func (q *sendQueue) stop() {
+	if q == nil {
+		return
+	}
+	q.Lock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.quit)
+	}
+	q.Unlock()
+	q.workers.Wait()
+}
+
+// stopping reports whether stop has been called.
+func (q *sendQueue) stopping() bool {
+	select {
+	case <-q.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// run sends the jobs queued for channelID until there are none left.
+func (q *sendQueue) run(channelID string) {
+	defer q.workers.Done()
+	for {
+		q.Lock()
+		jobs := q.pending[channelID]
+		if len(jobs) == 0 {
+			delete(q.pending, channelID)
+			q.Unlock()
+			return
+		}
+		job := jobs[0]
+		q.Unlock()
+
+		m, err := q.attempt(channelID, job)
+
+		q.Lock()
+		q.pending[channelID] = q.pending[channelID][1:]
+		q.Unlock()
+		job.done <- sendResult{m, err}
+	}
+}
+
+func (q *sendQueue) attempt(channelID string, job *sendJob) (*discordgo.Message, error) {
+	for attempt := 1; ; attempt++ {
+		m, err := job.send()
+		if err == nil || !job.retry || !retryableError(err) {
+			return m, err
+		}
+		if attempt >= q.maxAttempts || !q.wait(channelID, job, attempt, err) {
+			q.logger.Error("Giving up sending message", "channel", channelID, "attempts", attempt, "error", err)
+			q.metrics.observeDeadLetter(job.kind)
+			if q.deadLetter != nil {
+				q.deadLetter(&DeadLetter{ChannelID: channelID, Message: job.message, Attempts: attempt, Err: err})
+			}
+			return nil, err
+		}
+	}
+}
+
+// wait waits before job is retried after failing with err. It returns
+// false without waiting out the delay if the queue is stopped.
+func (q *sendQueue) wait(channelID string, job *sendJob, attempt int, err error) bool {
+	if q.stopping() {
+		return false
+	}
+	delay := q.baseDelay << uint(attempt-1)
+	if wait := retryAfter(err); wait > delay {
+		delay = wait
+	}
+	q.metrics.observeRetry(job.kind)
+	q.logger.Warn("Failed to send message, retrying", "channel", channelID, "attempt", attempt, "delay", delay, "error", err)
+	select {
+	case <-time.After(delay):
+		return true
+	case <-q.quit:
+		return false
+	}
+}
+
+// length returns the number of messages waiting to be sent, including
+// those being sent now.
+func (q *sendQueue) length() int {
+	if q == nil {
+		return 0
+	}
+	q.Lock()
+	defer q.Unlock()
+
+	n := 0
+	for _, jobs := range q.pending {
+		n += len(jobs)
+	}
+	return n
+}
+
+// retryableError reports whether err is likely to go away if the
+// request is made again: a network error, a rate limit or an error on
+// Discord's side.
+func retryableError(err error) bool {
+	if restErr, ok := err.(*discordgo.RESTError); ok {
+		if restErr.Response == nil {
+			return false
+		}
+		status := restErr.Response.StatusCode
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// retryAfter returns how long a rate limited request asked to wait.
+func retryAfter(err error) time.Duration {
+	restErr, ok := err.(*discordgo.RESTError)
+	if !ok || restErr.Response == nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(restErr.Response.Header.Get("Retry-After"), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
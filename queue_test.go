@@ -0,0 +1,143 @@
+package spudo
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func serverError() error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}}}
+}
+
+func TestSendQueueRetry(t *testing.T) {
+	var deadLetters []*DeadLetter
+	q := newSendQueue(3, newLogger(), newMetrics(), func(dl *DeadLetter) { deadLetters = append(deadLetters, dl) })
+	q.baseDelay = time.Millisecond
+
+	attempts := 0
+	m, err := q.do("chan", "text", "hello", true, func() (*discordgo.Message, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, serverError()
+		}
+		return &discordgo.Message{ID: "1"}, nil
+	})
+	if err != nil || m.ID != "1" || attempts != 3 {
+		t.Errorf("Expected success on third attempt - got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	if _, err := q.do("chan", "text", "hello", true, func() (*discordgo.Message, error) {
+		attempts++
+		return nil, serverError()
+	}); err == nil {
+		t.Error("Expected error after every attempt failed")
+	}
+	if attempts != 3 || len(deadLetters) != 1 || deadLetters[0].Message != "hello" {
+		t.Errorf("Expected one dead letter after 3 attempts - got %d dead letters after %d attempts", len(deadLetters), attempts)
+	}
+
+	attempts = 0
+	q.do("chan", "text", "hello", true, func() (*discordgo.Message, error) {
+		attempts++
+		return nil, errors.New("missing permissions")
+	})
+	if attempts != 1 {
+		t.Errorf("Expected permanent error not to be retried - got %d attempts", attempts)
+	}
+	if n := q.length(); n != 0 {
+		t.Errorf("Expected empty queue - got %d", n)
+	}
+}
+
+func TestSendQueueOrder(t *testing.T) {
+	q := newSendQueue(1, newLogger(), nil, nil)
+	release := make(chan struct{})
+	var sent []int
+	done := make(chan struct{})
+
+	go func() {
+		q.do("chan", "text", "", true, func() (*discordgo.Message, error) {
+			<-release
+			sent = append(sent, 0)
+			return nil, nil
+		})
+		close(done)
+	}()
+	for q.length() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	results := make(chan struct{}, 3)
+	for i := 1; i <= 3; i++ {
+		i := i
+		go q.do("chan", "text", "", true, func() (*discordgo.Message, error) {
+			sent = append(sent, i)
+			results <- struct{}{}
+			return nil, nil
+		})
+		for q.length() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if n := q.length(); n != 4 {
+		t.Fatalf("Expected 4 queued messages - got %d", n)
+	}
+
+	close(release)
+	<-done
+	for i := 0; i < 3; i++ {
+		<-results
+	}
+	for i, v := range sent {
+		if v != i {
+			t.Fatalf("Expected messages to be sent in order - got %v", sent)
+		}
+	}
+}
+
+func TestSendQueueStop(t *testing.T) {
+	var deadLetters []*DeadLetter
+	q := newSendQueue(5, newLogger(), newMetrics(), func(dl *DeadLetter) { deadLetters = append(deadLetters, dl) })
+	q.baseDelay = time.Hour
+
+	attempts := 0
+	failing := make(chan error, 1)
+	go func() {
+		_, err := q.do("chan", "text", "hello", true, func() (*discordgo.Message, error) {
+			attempts++
+			return nil, serverError()
+		})
+		failing <- err
+	}()
+	for q.length() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		q.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected stop not to wait for the retry delay")
+	}
+	if err := <-failing; err == nil || attempts != 1 || len(deadLetters) != 1 {
+		t.Errorf("Expected the send to give up when stopped - got %v after %d attempts", err, attempts)
+	}
+
+	sent := false
+	q.do("chan", "text", "", true, func() (*discordgo.Message, error) {
+		sent = true
+		return nil, nil
+	})
+	if !sent || q.length() != 0 {
+		t.Error("Expected sends after stop to be attempted without queueing")
+	}
+}
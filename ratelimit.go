@@ -0,0 +1,143 @@
+package spudo
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often idle per-client buckets are removed from a rateLimiter.
+const rateLimitCleanupInterval = time.Minute
+
+// RateLimitConfig limits how often a REST route can be used.
+type RateLimitConfig struct {
+	Route string  // Route the limit applies to, as passed to AddRESTRoute
+	Rate  float64 // Requests allowed per second
+	Burst int     // Requests allowed at once before the rate applies
+	PerIP bool    // Whether each client IP address has its own limit, rather than sharing one for the route
+}
+
+// WithRateLimit limits a route to rate requests per second, allowing
+// bursts of up to burst requests. If perIP is set, each client IP
+// address is limited separately. Requests over the limit receive a
+// 429 response with a Retry-After header.
+func WithRateLimit(rate float64, burst int, perIP bool) RESTRouteOption {
+	return func(rr *restRoute) {
+		rr.limiters = append(rr.limiters, newRateLimiter(rate, burst, perIP))
+	}
+}
+
+// rateLimiter is a token bucket limiter, either shared by every client
+// or with a bucket for each client IP address.
+type rateLimiter struct {
+	sync.Mutex
+	rate        float64
+	burst       float64
+	perIP       bool
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int, perIP bool) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		perIP:       perIP,
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+// allow takes a token for the request if one is available. If not,
+// it returns how long until one will be.
+func (rl *rateLimiter) allow(r *http.Request, now time.Time) (bool, time.Duration) {
+	key := ""
+	if rl.perIP {
+		key = clientIP(r)
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	rl.cleanup(now)
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rl.rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// cleanup removes buckets that have refilled completely, since they
+// are the same as a new bucket.
+func (rl *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(rl.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	rl.lastCleanup = now
+	for key, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// withRateLimit wraps next so requests over any of the route's limits
+// are rejected. Limits for the route in the Config's RESTRateLimits
+// are added to those given as options.
+func (sp *Spudo) withRateLimit(rr *restRoute, next http.Handler) http.Handler {
+	limiters := rr.limiters
+	for _, rlc := range sp.Config.RESTRateLimits {
+		if strings.Trim(rlc.Route, "/") == strings.Trim(rr.pattern, "/") {
+			limiters = append(limiters, newRateLimiter(rlc.Rate, rlc.Burst, rlc.PerIP))
+		}
+	}
+	if len(limiters) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		for _, rl := range limiters {
+			if ok, wait := rl.allow(r, now); !ok {
+				sp.logger.Warn("Rate limited REST request", "route", rr.pattern, "remote", r.RemoteAddr)
+				retryAfter := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeJSONError(w, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
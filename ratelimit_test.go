@@ -0,0 +1,76 @@
+package spudo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	bot := newSpudo()
+	bot.Config.RESTEnabled = true
+	bot.AddRESTRoute("limited", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, WithRateLimit(0.01, 2, true))
+
+	request := func(remote string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/limited", nil)
+		r.RemoteAddr = remote
+		bot.restRouter.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within burst to succeed - got %d", i+1, w.Code)
+		}
+	}
+	w := request("10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 after burst - got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if w := request("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected another client to have its own limit - got %d", w.Code)
+	}
+}
+
+func TestRateLimitAfterAuth(t *testing.T) {
+	bot := newSpudo()
+	bot.Config.RESTEnabled = true
+	bot.Config.RESTAPIKeys = []string{"key"}
+	bot.AddRESTRoute("limited", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, RequireAPIKey(), WithRateLimit(0.01, 1, false))
+
+	request := func(key string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/limited", nil)
+		r.Header.Set("X-API-Key", key)
+		bot.restRouter.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := request("nope"); code != http.StatusUnauthorized {
+			t.Fatalf("Expected unauthenticated request %d to be rejected - got %d", i+1, code)
+		}
+	}
+	if code := request("key"); code != http.StatusOK {
+		t.Errorf("Expected rejected requests not to use up the rate limit - got %d", code)
+	}
+	if code := request("key"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 after burst - got %d", code)
+	}
+}
+
+func TestJoinMessages(t *testing.T) {
+	batches := joinMessages([]string{"one", "two", strings.Repeat("x", 10)}, 10)
+	if len(batches) != 2 || batches[0] != "one\ntwo" || batches[1] != strings.Repeat("x", 10) {
+		t.Errorf("Unexpected batches: %q", batches)
+	}
+}
@@ -0,0 +1,299 @@
+package spudo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RelayConfig defines a REST route that renders the JSON body of a
+// webhook with a template and posts the result to channels.
+type RelayConfig struct {
+	Route           string           // Route the relay listens on
+	Channels        []string         // IDs of channels the message is sent to
+	Template        string           // text/template for the message content
+	Embed           *RelayEmbed      // Templates for an embed sent with the message
+	RequireAPIKey   bool             // Whether requests need one of the RESTAPIKeys
+	Secret          string           // Name of the secret in RESTSecrets used to verify requests
+	SignatureHeader string           // Header containing an HMAC-SHA256 of the body, defaults to X-Hub-Signature-256
+	SecretHeader    string           // Header containing the secret itself, used instead of a signature if set
+	Coalesce        bool             // Whether to combine messages received within CoalesceWindow, only used without an embed or webhook
+	Webhook         *WebhookIdentity // Identity to send the message as through each channel's webhook, rather than as the bot
+}
+
+// RelayEmbed contains the text/templates for an embed sent by a relay.
+type RelayEmbed struct {
+	Title       string
+	Description string
+	URL         string
+	Footer      string
+	Color       int
+}
+
+// relay is a RelayConfig with its templates parsed.
+type relay struct {
+	config      RelayConfig
+	content     *template.Template
+	title       *template.Template
+	description *template.Template
+	url         *template.Template
+	footer      *template.Template
+}
+
+var relayFuncs = template.FuncMap{
+	// Cuts s to n characters, never in the middle of one
+	"truncate": func(n int, s string) string {
+		if n < 0 {
+			n = 0
+		}
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return string(runes[:n])
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// Replaces a missing or empty value with def
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// Added to the end of every action that prints by emptyMissing
+	"orEmpty": func(v interface{}) interface{} {
+		if v == nil {
+			return ""
+		}
+		return v
+	},
+}
+
+func newRelay(config RelayConfig) (*relay, error) {
+	if config.Route == "" {
+		return nil, errors.New("relay has no route")
+	}
+	if len(config.Channels) == 0 {
+		return nil, errors.New("relay has no channels")
+	}
+	if config.Template == "" && config.Embed == nil {
+		return nil, errors.New("relay has no template or embed")
+	}
+
+	rl := &relay{config: config}
+	var err error
+	if rl.content, err = parseRelayTemplate("template", config.Template); err != nil {
+		return nil, err
+	}
+	if config.Embed != nil {
+		if rl.title, err = parseRelayTemplate("title", config.Embed.Title); err != nil {
+			return nil, err
+		}
+		if rl.description, err = parseRelayTemplate("description", config.Embed.Description); err != nil {
+			return nil, err
+		}
+		if rl.url, err = parseRelayTemplate("url", config.Embed.URL); err != nil {
+			return nil, err
+		}
+		if rl.footer, err = parseRelayTemplate("footer", config.Embed.Footer); err != nil {
+			return nil, err
+		}
+	}
+	return rl, nil
+}
+
+func parseRelayTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New(name).Funcs(relayFuncs).Parse(text)
+	if err != nil {
+		return nil, errors.New("invalid " + name + " template - " + err.Error())
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			emptyMissing(tmpl.Tree.Root)
+		}
+	}
+	return t, nil
+}
+
+// emptyMissing pipes the value of every action in list that prints
+// something into orEmpty. Keys missing from the decoded JSON, and JSON
+// nulls, then render as nothing rather than "<no value>" or "<nil>".
+func emptyMissing(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			// Actions that declare a variable don't print anything
+			if len(n.Pipe.Decl) == 0 {
+				n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+					NodeType: parse.NodeCommand,
+					Pos:      n.Pos,
+					Args:     []parse.Node{parse.NewIdentifier("orEmpty").SetPos(n.Pos)},
+				})
+			}
+		case *parse.IfNode:
+			emptyMissing(n.List)
+			emptyMissing(n.ElseList)
+		case *parse.RangeNode:
+			emptyMissing(n.List)
+			emptyMissing(n.ElseList)
+		case *parse.WithNode:
+			emptyMissing(n.List)
+			emptyMissing(n.ElseList)
+		}
+	}
+}
+
+// options returns the route options that apply the relay's auth
+// settings.
+func (rl *relay) options() []RESTRouteOption {
+	opts := []RESTRouteOption{WithMethods(http.MethodPost)}
+	if rl.config.RequireAPIKey {
+		opts = append(opts, RequireAPIKey())
+	}
+	if rl.config.Secret != "" {
+		switch {
+		case rl.config.SecretHeader != "":
+			opts = append(opts, RequireSharedSecret(rl.config.Secret, rl.config.SecretHeader))
+		case rl.config.SignatureHeader != "":
+			opts = append(opts, RequireSignature(rl.config.Secret, rl.config.SignatureHeader))
+		default:
+			opts = append(opts, RequireGitHubSignature(rl.config.Secret))
+		}
+	}
+	return opts
+}
+
+// render executes the relay's templates against data. It returns nil
+// if every template rendered empty, meaning there is nothing to send.
+func (rl *relay) render(data interface{}) (*discordgo.MessageSend, error) {
+	ms := &discordgo.MessageSend{}
+	var err error
+	if ms.Content, err = executeRelayTemplate(rl.content, data); err != nil {
+		return nil, err
+	}
+	if rl.config.Embed != nil {
+		e := NewEmbed().SetColor(rl.config.Embed.Color)
+		fields := []struct {
+			t   *template.Template
+			set func(string) *Embed
+		}{
+			{rl.title, e.SetTitle},
+			{rl.description, e.SetDescription},
+			{rl.url, e.SetURL},
+			{rl.footer, func(s string) *Embed { return e.SetFooter(s) }},
+		}
+		empty := true
+		for _, f := range fields {
+			text, err := executeRelayTemplate(f.t, data)
+			if err != nil {
+				return nil, err
+			}
+			if text != "" {
+				f.set(text)
+				empty = false
+			}
+		}
+		if !empty {
+			ms.Embed = e.MessageEmbed
+		}
+	}
+	if ms.Content == "" && ms.Embed == nil {
+		return nil, nil
+	}
+	return ms, nil
+}
+
+func executeRelayTemplate(t *template.Template, data interface{}) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// addRelays adds a REST route for each relay in the Config. Relays
+// with invalid settings are logged and skipped.
+func (sp *Spudo) addRelays() {
+	for _, config := range sp.Config.Relays {
+		rl, err := newRelay(config)
+		if err != nil {
+			sp.logger.Error("Failed to add relay", "route", config.Route, "error", err)
+			continue
+		}
+		sp.AddRESTRoute(config.Route, sp.relayHandler(rl), rl.options()...)
+	}
+}
+
+// relayHandler returns the handler for a relay. The response reports
+// how many channels the message was sent to, or what went wrong.
+func (sp *Spudo) relayHandler(rl *relay) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data interface{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+
+		ms, err := rl.render(data)
+		if err != nil {
+			sp.logger.Error("Failed to render relay template", "route", rl.config.Route, "error", err)
+			writeJSONError(w, http.StatusUnprocessableEntity, "failed to render template: "+err.Error())
+			return
+		}
+		if ms == nil {
+			writeJSON(w, http.StatusOK, map[string]int{"sent": 0})
+			return
+		}
+		if rl.config.Coalesce && ms.Embed == nil && rl.config.Webhook == nil {
+			for _, chanID := range rl.config.Channels {
+				sp.SendCoalesced(chanID, ms.Content)
+			}
+			writeJSON(w, http.StatusAccepted, map[string]int{"queued": len(rl.config.Channels)})
+			return
+		}
+
+		var failed []string
+		for _, chanID := range rl.config.Channels {
+			var err error
+			if rl.config.Webhook != nil {
+				_, err = sp.SendWebhookMessage(chanID, ms, *rl.config.Webhook)
+			} else {
+				_, err = sp.sendMessageSend(chanID, ms, sp.mentions)
+			}
+			if err != nil {
+				sp.logger.Error("Failed to send relay message", "route", rl.config.Route, "channel", chanID, "error", err)
+				failed = append(failed, chanID)
+			}
+		}
+		if len(failed) > 0 {
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+				"error":  "failed to send message to some channels",
+				"sent":   len(rl.config.Channels) - len(failed),
+				"failed": failed,
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"sent": len(rl.config.Channels)})
+	}
+}
@@ -0,0 +1,145 @@
+package spudo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRelayRender(t *testing.T) {
+	rl, err := newRelay(RelayConfig{
+		Route:    "alerts",
+		Channels: []string{"123"},
+		Template: `{{.alert.name | upper}} is {{.alert.state}}`,
+		Embed: &RelayEmbed{
+			Title: `{{.alert.name}}`,
+			URL:   `{{.alert.url}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error creating relay - %s", err.Error())
+	}
+
+	var data interface{}
+	body := `{"alert": {"name": "disk", "state": "firing"}}`
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := rl.render(data)
+	if err != nil {
+		t.Fatalf("Error rendering relay - %s", err.Error())
+	}
+	if ms.Content != "DISK is firing" {
+		t.Errorf("Unexpected content - got %q", ms.Content)
+	}
+	if ms.Embed == nil || ms.Embed.Title != "disk" || ms.Embed.URL != "" {
+		t.Errorf("Unexpected embed - got %+v", ms.Embed)
+	}
+}
+
+func TestRelayInvalidTemplate(t *testing.T) {
+	_, err := newRelay(RelayConfig{
+		Route:    "alerts",
+		Channels: []string{"123"},
+		Template: `{{.alert.name`,
+	})
+	if err == nil {
+		t.Error("Expected error for invalid template")
+	}
+}
+
+func TestRelayTruncate(t *testing.T) {
+	truncate := relayFuncs["truncate"].(func(int, string) string)
+	tests := []struct {
+		n    int
+		s    string
+		want string
+	}{
+		{5, "short", "short"},
+		{3, "longer", "lon"},
+		{2, "héllo", "hé"},
+		{3, "日本語テキスト", "日本語"},
+		{1, "👍👍", "👍"},
+		{-1, "text", ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate %d %q: expected %q - got %q", tt.n, tt.s, tt.want, got)
+		}
+	}
+}
+
+func TestRelayMissingKeys(t *testing.T) {
+	var data interface{}
+	body := `{"alert": {"name": "disk", "runbook": null, "note": "<no value> is fine"}}`
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{.alert.name}} {{.alert.url}}`, "disk"},
+		{`{{.alert.runbook}}`, ""},
+		{`{{.alert.note}}`, "<no value> is fine"},
+		{`{{.alert.url | default "no link"}}`, "no link"},
+		{`{{.alert.summary | default "" | truncate 3}}`, ""},
+		{`{{$u := .alert.url}}{{if $u}}{{$u}}{{else}}none{{end}}`, "none"},
+		{`{{range $k, $v := .alert}}{{if eq $k "runbook"}}[{{$v}}]{{end}}{{end}}`, "[]"},
+		{`{{with .alert}}{{.name}}{{.owner}}{{end}}`, "disk"},
+	}
+	for _, tt := range tests {
+		rl, err := newRelay(RelayConfig{Route: "alerts", Channels: []string{"123"}, Template: tt.template})
+		if err != nil {
+			t.Fatalf("Error creating relay - %s", err.Error())
+		}
+		ms, err := rl.render(data)
+		if err != nil {
+			t.Errorf("%s: error rendering - %s", tt.template, err.Error())
+			continue
+		}
+		got := ""
+		if ms != nil {
+			got = ms.Content
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q - got %q", tt.template, tt.want, got)
+		}
+	}
+}
+
+func TestRelayLongContent(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+
+	rl, err := newRelay(RelayConfig{
+		Route:    "logs",
+		Channels: []string{"channel"},
+		Template: `{{.log}}`,
+		Embed:    &RelayEmbed{Title: `{{.job}}`},
+	})
+	if err != nil {
+		t.Fatalf("Error creating relay - %s", err.Error())
+	}
+	body := `{"job": "build", "log": "` + strings.Repeat("line ", 500) + `"}`
+	w := httptest.NewRecorder()
+	sp.relayHandler(rl)(w, httptest.NewRequest("POST", "/logs", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body.String())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected the content split in two followed by the embed - got %d lines", len(lines))
+	}
+	for i, line := range lines[:2] {
+		if !strings.HasPrefix(line, "[#channel] spudo (") || !strings.Contains(line, "line line") {
+			t.Errorf("Expected line %d to be part of the content - got %.40q", i, line)
+		}
+	}
+	if !strings.HasSuffix(lines[2], "| title: build") {
+		t.Errorf("Expected the embed after the content - got %q", lines[2])
+	}
+}
@@ -0,0 +1,137 @@
+package spudo
+
+import (
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var errNothingToEdit = errors.New("no response to edit")
+
+// Edit can be sent on a channel returned by a command to replace the
+// content of the last message sent in response, rather than sending a
+// new one. It is useful for progress updates.
+type Edit string
+
+// Deferred is a response that is acknowledged straight away and
+// updated later by Work. It is created with Defer.
+type Deferred struct {
+	Ack  interface{}    // Response sent immediately, a string, *Embed, *Complex or *MentionResponse
+	Work func(r *Reply) // Function that does the work and updates the response through r
+}
+
+// Defer returns a response that sends ack immediately, then runs work
+// so it can edit the acknowledgement or follow it up once it is done.
+func Defer(ack interface{}, work func(r *Reply)) *Deferred {
+	return &Deferred{Ack: ack, Work: work}
+}
+
+// Reply is the response to a command that has already been sent, used
+// to update it.
+type Reply struct {
+	sp        *Spudo
+	m         *discordgo.MessageCreate // Message the command was used in
+	private   bool                     // Whether responses are sent to the user directly
+	channelID string
+	message   *discordgo.Message // Last message sent in response, nil if sending failed
+}
+
+// newReply returns a Reply for the command in m, sending responses
+// directly to the user if private is true.
+func (sp *Spudo) newReply(m *discordgo.MessageCreate, private bool) *Reply {
+	return &Reply{sp: sp, m: m, private: private, channelID: m.ChannelID}
+}
+
+// Message returns the last message sent in response to the command.
+func (r *Reply) Message() *discordgo.Message {
+	return r.message
+}
+
+// Edit replaces the content of the last message sent in response to
+// the command.
+func (r *Reply) Edit(content string) (*discordgo.Message, error) {
+	if r.message == nil {
+		return nil, errNothingToEdit
+	}
+	m, err := r.sp.EditMessage(r.message.ChannelID, r.message.ID, content)
+	if err == nil {
+		r.message = m
+	}
+	return m, err
+}
+
+// FollowUp sends message to the channel the command was used in, or
+// to the user directly for private commands. message can be a string,
+// *Embed, *Complex or *MentionResponse. Later edits apply to the new
+// message.
+func (r *Reply) FollowUp(message interface{}) (*discordgo.Message, error) {
+	var m *discordgo.Message
+	var err error
+	if r.private {
+		m, err = r.sp.sendPrivateMessage(r.m.Author.ID, message)
+	} else {
+		m, err = r.sp.sendTo(r.channelID, message)
+	}
+	if err == nil {
+		r.message = m
+	}
+	return m, err
+}
+
+// respond sends the first response to the command. Public string
+// responses mention the user who used the command.
+func (r *Reply) respond(resp interface{}) {
+	if r.private {
+		r.message, _ = r.sp.sendPrivateMessage(r.m.Author.ID, resp)
+		return
+	}
+	r.message, _ = r.sp.respondWith(r.m, resp)
+}
+
+// respondWith sends resp as the response to the command in m. String
+// responses mention the user who used the command.
+func (sp *Spudo) respondWith(m *discordgo.MessageCreate, resp interface{}) (*discordgo.Message, error) {
+	switch v := resp.(type) {
+	case string:
+		return sp.respondToUser(m, v)
+	case *MentionResponse:
+		if s, ok := v.Message.(string); ok {
+			return sp.sendText(m.ChannelID, m.Author.Mention()+" "+s, v.AllowedMentions)
+		}
+	}
+	return sp.sendTo(m.ChannelID, resp)
+}
+
+// runDeferred sends the acknowledgement of d, then runs its work.
+// stopTyping is called once the acknowledgement has been sent.
+func (sp *Spudo) runDeferred(r *Reply, d *Deferred, stopTyping func()) {
+	r.respond(d.Ack)
+	stopTyping()
+	if d.Work != nil {
+		d.Work(r)
+	}
+}
+
+// streamResponses sends each response received on responses until it
+// is closed. The first is sent like any other response, Edit values
+// update the last message and the rest are sent as follow ups.
+// stopTyping is called once the first response has been sent.
+func (sp *Spudo) streamResponses(r *Reply, responses <-chan interface{}, stopTyping func()) {
+	first := true
+	for resp := range responses {
+		switch v := resp.(type) {
+		case Edit:
+			if _, err := r.Edit(string(v)); err == errNothingToEdit {
+				sp.logger.Warn("Failed to edit command response - nothing sent yet", "channel", r.channelID)
+			}
+		default:
+			if first {
+				r.respond(v)
+				first = false
+				stopTyping()
+			} else {
+				r.FollowUp(v)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package spudo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func expectOutput(t *testing.T, out *bytes.Buffer, want ...string) {
+	t.Helper()
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", strings.Join(want, "\n"), out.String())
+	}
+}
+
+func TestReplyEditWithoutMessage(t *testing.T) {
+	r := &Reply{sp: newSpudo(), channelID: "channel"}
+	if _, err := r.Edit("done"); err != errNothingToEdit {
+		t.Errorf("Expected errNothingToEdit when the acknowledgement wasn't sent - got %v", err)
+	}
+}
+
+func TestRunDeferred(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!command")
+
+	stopped := 0
+	ran := false
+	sp.runDeferred(sp.newReply(m, false), Defer("Working...", func(r *Reply) {
+		ran = true
+		if stopped != 1 {
+			t.Errorf("Expected typing to stop before the work runs - stopped %d times", stopped)
+		}
+		if r.Message() == nil || r.Message().ID != "1" {
+			t.Errorf("Expected acknowledgement to be the reply's message - got %+v", r.Message())
+		}
+		r.Edit("Done")
+		r.FollowUp("Link")
+		r.Edit("Link!")
+	}), func() { stopped++ })
+
+	if !ran {
+		t.Fatal("Expected the work to run")
+	}
+	expectOutput(t, out,
+		"[#channel] spudo (1): <@user> Working...",
+		"[#channel] spudo edited 1: Done",
+		"[#channel] spudo (2): Link",
+		"[#channel] spudo edited 2: Link!",
+	)
+}
+
+func TestRunDeferredPrivate(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!command")
+
+	sp.runDeferred(sp.newReply(m, true), Defer("Working...", func(r *Reply) {
+		r.Edit("Done")
+		r.FollowUp("Link")
+	}), func() {})
+
+	expectOutput(t, out,
+		"[#dm-user] spudo (1): Working...",
+		"[#dm-user] spudo edited 1: Done",
+		"[#dm-user] spudo (2): Link",
+	)
+}
+
+func TestStreamResponses(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!command")
+
+	responses := make(chan interface{}, 5)
+	responses <- Edit("nothing to edit yet")
+	responses <- "one"
+	responses <- Edit("one!")
+	responses <- "two"
+	responses <- WithMentions("three", NoMentions())
+	close(responses)
+
+	stopped := 0
+	// Returns once the channel is closed
+	sp.streamResponses(sp.newReply(m, false), responses, func() { stopped++ })
+
+	if stopped != 1 {
+		t.Errorf("Expected typing to be stopped once - stopped %d times", stopped)
+	}
+	expectOutput(t, out,
+		"[#channel] spudo (1): <@user> one",
+		"[#channel] spudo edited 1: one!",
+		"[#channel] spudo (2): two",
+		"[#channel] spudo (3): three",
+	)
+}
+
+func TestStreamResponsesPrivate(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!command")
+
+	responses := make(chan interface{}, 2)
+	responses <- "one"
+	responses <- "two"
+	close(responses)
+	sp.streamResponses(sp.newReply(m, true), responses, func() {})
+
+	expectOutput(t, out,
+		"[#dm-user] spudo (1): one",
+		"[#dm-user] spudo (2): two",
+	)
+}
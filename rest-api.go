@@ -1,13 +1,197 @@
 package spudo
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
-func (sp *Spudo) startRESTApi() {
-	http.HandleFunc("/", http.NotFound)
-	err := http.ListenAndServe(":"+sp.Config.RESTPort, nil)
+const restShutdownTimeout = 10 * time.Second
+
+type pathParamsKey struct{}
+
+// RESTRouteOption configures a route added with AddRESTRoute.
+type RESTRouteOption func(*restRoute)
+
+// WithMethods restricts a route to the given HTTP methods. Requests
+// using any other method receive a 405 response. Routes accept every
+// method by default.
+func WithMethods(methods ...string) RESTRouteOption {
+	return func(rr *restRoute) {
+		for _, m := range methods {
+			rr.methods = append(rr.methods, strings.ToUpper(m))
+		}
+	}
+}
+
+// PathParam returns the value of the path parameter name for a
+// request to a route containing a {name} segment.
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+type restRoute struct {
+	pattern  string
+	segments []string
+	subtree  bool
+	methods  []string
+	auth     []authCheck
+	limiters []*rateLimiter
+	handler  http.Handler
+}
+
+// match returns whether path matches the route along with any path
+// parameters in it.
+func (rr *restRoute) match(path string) (map[string]string, bool) {
+	segments := splitPath(path)
+	if len(segments) < len(rr.segments) || (!rr.subtree && len(segments) != len(rr.segments)) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, seg := range rr.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (rr *restRoute) allows(method string) bool {
+	if len(rr.methods) == 0 {
+		return true
+	}
+	for _, m := range rr.methods {
+		if m == method || (m == http.MethodGet && method == http.MethodHead) {
+			return true
+		}
+	}
+	return false
+}
+
+// router is the http.Handler for the REST API. Routes are matched by
+// path segment, where a segment written as {name} matches anything
+// and is made available through PathParam. A route ending in a slash
+// matches every path beneath it.
+type router struct {
+	routes []*restRoute
+}
+
+func newRouter() *router {
+	return &router{}
+}
+
+func (rt *router) handle(pattern string, handler http.Handler, opts ...RESTRouteOption) *restRoute {
+	rr := &restRoute{
+		pattern:  pattern,
+		segments: splitPath(pattern),
+		subtree:  strings.HasSuffix(pattern, "/"),
+		handler:  handler,
+	}
+	for _, opt := range opts {
+		opt(rr)
+	}
+	rt.routes = append(rt.routes, rr)
+	return rr
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, rr := range rt.routes {
+		params, ok := rr.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if !rr.allows(r.Method) {
+			allowed = append(allowed, rr.methods...)
+			continue
+		}
+		if params != nil {
+			r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+		}
+		rr.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// startRESTApi starts listening on the configured address and serves
+// the REST routes until stopRESTApi is called.
+func (sp *Spudo) startRESTApi() error {
+	sp.restServer = &http.Server{
+		Addr:         net.JoinHostPort(sp.Config.RESTAddress, sp.Config.RESTPort),
+		Handler:      sp.restRouter,
+		ReadTimeout:  time.Duration(sp.Config.RESTReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(sp.Config.RESTWriteTimeout) * time.Second,
+	}
+
+	ln, err := net.Listen("tcp", sp.restServer.Addr)
 	if err != nil {
-		sp.logger.info("Error on creating listener: ", err)
+		return err
+	}
+
+	if sp.Config.RESTTLSCert != "" || sp.Config.RESTTLSKey != "" {
+		tr, err := newTLSReloader(sp.Config.RESTTLSCert, sp.Config.RESTTLSKey, sp.Config.RESTClientCA, sp.logger)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		sp.restServer.TLSConfig = tr.serverConfig()
+		ln = tls.NewListener(ln, sp.restServer.TLSConfig)
+		sp.logger.Info("REST API using TLS", "client_certs", sp.Config.RESTClientCA != "")
 	}
+	sp.logger.Info("REST API listening", "address", ln.Addr().String())
+	sp.setRESTListening(true)
+
+	go func() {
+		if err := sp.restServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			sp.logger.Error("REST API stopped unexpectedly", "error", err)
+		}
+		sp.setRESTListening(false)
+	}()
+	return nil
+}
+
+// stopRESTApi gracefully shuts down the REST server, waiting for
+// in-flight requests to finish.
+func (sp *Spudo) stopRESTApi() {
+	if sp.restServer == nil {
+		return
+	}
+	sp.setRESTListening(false)
+	ctx, cancel := context.WithTimeout(context.Background(), restShutdownTimeout)
+	defer cancel()
+	if err := sp.restServer.Shutdown(ctx); err != nil {
+		sp.logger.Error("Error shutting down REST API", "error", err)
+	}
+}
+
+func (sp *Spudo) setRESTListening(listening bool) {
+	sp.Lock()
+	sp.restListening = listening
+	sp.Unlock()
 }
@@ -0,0 +1,39 @@
+package spudo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := newRouter()
+	rt.handle("/channels/{id}/messages", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(PathParam(r, "id")))
+	}), WithMethods("post"))
+	rt.handle("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("static"))
+	}))
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"POST", "/channels/123/messages", http.StatusOK, "123"},
+		{"GET", "/channels/123/messages", http.StatusMethodNotAllowed, ""},
+		{"POST", "/channels/123", http.StatusNotFound, ""},
+		{"GET", "/static/css/site.css", http.StatusOK, "static"},
+		{"GET", "/other", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %s - got status %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s - got body %q, want %q", tt.method, tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package spudo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotateTimeFormat = "20060102T150405"
+
+// LogFileConfig contains the options for a log file written to disk.
+type LogFileConfig struct {
+	Path        string // Path of the log file, logging to disk is disabled if empty
+	MaxSize     int    // Megabytes the file can grow to before it is rotated, 0 disables size based rotation
+	RotateHours int    // Hours a file is written to before it is rotated, 0 disables age based rotation
+	MaxAge      int    // Days rotated files are kept for, 0 keeps them regardless of age
+	MaxBackups  int    // Number of rotated files that are kept, 0 keeps all of them
+}
+
+// rotatingFile is an io.WriteCloser that writes to a file, moving it
+// aside to a timestamped backup when it grows too large or too old
+// and removing backups that fall outside the retention settings.
+type rotatingFile struct {
+	sync.Mutex
+	config LogFileConfig
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func newRotatingFile(config LogFileConfig) (*rotatingFile, error) {
+	rf := &rotatingFile{config: config}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.Lock()
+	defer rf.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.shouldRotate(len(p)) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file. Writes after Close return an error.
+func (rf *rotatingFile) Close() error {
+	rf.Lock()
+	defer rf.Unlock()
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *rotatingFile) shouldRotate(writeLen int) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.config.MaxSize > 0 && rf.size+int64(writeLen) > int64(rf.config.MaxSize)*1024*1024 {
+		return true
+	}
+	if rf.config.RotateHours > 0 && time.Since(rf.opened) >= time.Duration(rf.config.RotateHours)*time.Hour {
+		return true
+	}
+	return false
+}
+
+func (rf *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(rf.config.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	rf.opened = time.Now()
+	return nil
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+
+	if err := os.Rename(rf.config.Path, rf.backupName(time.Now())); err != nil {
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	return rf.removeOldBackups()
+}
+
+// backupName returns the path a rotated file is moved to, which is
+// the log path with a timestamp inserted before the extension.
+func (rf *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(rf.config.Path)
+	base := strings.TrimSuffix(rf.config.Path, ext)
+	name := base + "-" + t.UTC().Format(rotateTimeFormat) + ext
+	for i := 1; fileExists(name); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.UTC().Format(rotateTimeFormat), i, ext)
+	}
+	return name
+}
+
+// removeOldBackups deletes rotated files that are older than MaxAge
+// or beyond the newest MaxBackups.
+func (rf *rotatingFile) removeOldBackups() error {
+	if rf.config.MaxAge <= 0 && rf.config.MaxBackups <= 0 {
+		return nil
+	}
+	dir := filepath.Dir(rf.config.Path)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type backup struct {
+		info os.FileInfo
+		time time.Time
+		n    int
+	}
+	var backups []backup
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if t, n, ok := rf.parseBackupName(f.Name()); ok {
+			backups = append(backups, backup{f, t, n})
+		}
+	}
+	// Newest first, backups made in the same second are numbered in order
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.After(backups[j].time)
+		}
+		return backups[i].n > backups[j].n
+	})
+
+	cutoff := time.Now().Add(-time.Duration(rf.config.MaxAge) * 24 * time.Hour)
+	for i, b := range backups {
+		tooMany := rf.config.MaxBackups > 0 && i >= rf.config.MaxBackups
+		tooOld := rf.config.MaxAge > 0 && b.info.ModTime().Before(cutoff)
+		if tooMany || tooOld {
+			if err := os.Remove(filepath.Join(dir, b.info.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseBackupName reports whether name is a file written by
+// backupName for this log, returning its timestamp and sequence number.
+// Only <base>-<timestamp>[.N]<ext> matches, so the files of another log
+// sharing the directory and prefix (bot-audit.log next to bot.log) are
+// never mistaken for backups.
+func (rf *rotatingFile) parseBackupName(name string) (time.Time, int, bool) {
+	ext := filepath.Ext(rf.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(rf.config.Path), ext) + "-"
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+		return time.Time{}, 0, false
+	}
+	stamp := name[len(prefix) : len(name)-len(ext)]
+
+	n := 0
+	if i := strings.IndexByte(stamp, '.'); i >= 0 {
+		var err error
+		if n, err = strconv.Atoi(stamp[i+1:]); err != nil || n < 1 {
+			return time.Time{}, 0, false
+		}
+		stamp = stamp[:i]
+	}
+	t, err := time.Parse(rotateTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, n, true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,188 @@
+package spudo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rf, err := newRotatingFile(LogFileConfig{
+		Path:       filepath.Join(dir, "bot.log"),
+		MaxSize:    1,
+		MaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatalf("Error opening log file - %s", err.Error())
+	}
+	defer rf.Close()
+
+	chunk := bytes.Repeat([]byte("a"), 600*1024)
+	for i := 0; i < 5; i++ {
+		if _, err := rf.Write(chunk); err != nil {
+			t.Fatalf("Error writing log file - %s", err.Error())
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "bot*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected current log and one backup - got %v", files)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bot.log")
+
+	rf, err := newRotatingFile(LogFileConfig{Path: path, RotateHours: 1})
+	if err != nil {
+		t.Fatalf("Error opening log file - %s", err.Error())
+	}
+	defer rf.Close()
+
+	rf.Write([]byte("first\n"))
+	rf.Write([]byte("second\n"))
+	if files, _ := filepath.Glob(filepath.Join(dir, "bot-*.log")); len(files) != 0 {
+		t.Fatalf("Expected no rotation within RotateHours - got %v", files)
+	}
+
+	rf.opened = time.Now().Add(-2 * time.Hour)
+	rf.Write([]byte("third\n"))
+
+	files, _ := filepath.Glob(filepath.Join(dir, "bot-*.log"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one backup after RotateHours - got %v", files)
+	}
+	if b, _ := ioutil.ReadFile(files[0]); string(b) != "first\nsecond\n" {
+		t.Errorf("Expected the backup to hold the old lines - got %q", b)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "third\n" {
+		t.Errorf("Expected a new file after rotating - got %q", b)
+	}
+}
+
+func TestRotatingFilePruning(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LogFileConfig
+		kept   []string // Old backups expected to survive a rotation
+	}{
+		{"keep all", LogFileConfig{}, []string{"bot-20200101T000000.log", "bot-20200102T000000.log", "bot-20200103T000000.log"}},
+		{"max backups", LogFileConfig{MaxBackups: 2}, []string{"bot-20200103T000000.log"}},
+		{"max age", LogFileConfig{MaxAge: 7}, []string{"bot-20200103T000000.log"}},
+		{"both", LogFileConfig{MaxAge: 7, MaxBackups: 1}, nil},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "spudo-rotate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// Only the newest old backup was written recently
+		old := []string{"bot-20200101T000000.log", "bot-20200102T000000.log", "bot-20200103T000000.log"}
+		for i, name := range old {
+			p := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(p, []byte("old\n"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			if i < len(old)-1 {
+				mtime := time.Now().Add(-30 * 24 * time.Hour)
+				os.Chtimes(p, mtime, mtime)
+			}
+		}
+		// Not a backup of bot.log, so never removed
+		ioutil.WriteFile(filepath.Join(dir, "other-20200101T000000.log"), []byte("other\n"), 0640)
+
+		config := tt.config
+		config.Path = filepath.Join(dir, "bot.log")
+		config.MaxSize = 1
+		rf, err := newRotatingFile(config)
+		if err != nil {
+			t.Fatalf("Error opening log file - %s", err.Error())
+		}
+		chunk := bytes.Repeat([]byte("a"), 600*1024)
+		rf.Write(chunk)
+		rf.Write(chunk)
+		rf.Close()
+
+		for _, name := range old {
+			want := false
+			for _, k := range tt.kept {
+				want = want || k == name
+			}
+			if got := fileExists(filepath.Join(dir, name)); got != want {
+				t.Errorf("%s: expected %s to exist: %t - got %t", tt.name, name, want, got)
+			}
+		}
+		if !fileExists(filepath.Join(dir, "other-20200101T000000.log")) {
+			t.Errorf("%s: expected files of other logs to be kept", tt.name)
+		}
+		if files, _ := filepath.Glob(filepath.Join(dir, "bot-*.log")); len(files) != len(tt.kept)+1 {
+			t.Errorf("%s: expected the new backup and %d old ones - got %v", tt.name, len(tt.kept), files)
+		}
+	}
+}
+
+func TestRotatingFileSharedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var sinks []*rotatingFile
+	for _, name := range []string{"bot.log", "bot-audit.log"} {
+		rf, err := newRotatingFile(LogFileConfig{Path: filepath.Join(dir, name), MaxSize: 1, MaxBackups: 1})
+		if err != nil {
+			t.Fatalf("Error opening log file - %s", err.Error())
+		}
+		defer rf.Close()
+		sinks = append(sinks, rf)
+	}
+
+	// Both sinks rotate several times within the same second
+	chunk := bytes.Repeat([]byte("a"), 600*1024)
+	for i := 0; i < 4; i++ {
+		for _, rf := range sinks {
+			if _, err := rf.Write(chunk); err != nil {
+				t.Fatalf("Error writing log file - %s", err.Error())
+			}
+		}
+	}
+
+	for _, name := range []string{"bot.log", "bot-audit.log"} {
+		if !fileExists(filepath.Join(dir, name)) {
+			t.Errorf("Expected %s to be kept by the other sink's pruning", name)
+		}
+	}
+	var botBackups, auditBackups int
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		if _, _, ok := sinks[0].parseBackupName(f.Name()); ok {
+			botBackups++
+		}
+		if _, _, ok := sinks[1].parseBackupName(f.Name()); ok {
+			auditBackups++
+		}
+	}
+	if botBackups != 1 || auditBackups != 1 || len(files) != 4 {
+		t.Errorf("Expected each log and one backup of it - got %d files", len(files))
+	}
+}
@@ -1,51 +1,165 @@
 package spudo
 
 import (
+	"strings"
+	"unicode/utf8"
+
 	"github.com/bwmarrin/discordgo"
 )
 
 type session struct {
 	*discordgo.Session
-	logger *spudoLogger
+	logger          Logger
+	metrics         *metrics
+	attachThreshold int
+	queue           *sendQueue
+	mentions        *AllowedMentions
+	backend         Backend
 }
 
-func newSession(token string, logger *spudoLogger) (*session, error) {
+func newSession(token string, logger Logger) (*session, error) {
 	ss := &session{}
 	var err error
 	ss.logger = logger
-	ss.Session, err = discordgo.New("Bot " + token)
-	return ss, err
+	if ss.Session, err = discordgo.New("Bot " + token); err != nil {
+		return nil, err
+	}
+	ss.backend = &discordBackend{ss.Session}
+	return ss, nil
 }
 
 // SendMessage is a helper function around ChannelMessageSend from
-// discordgo. It will send a message to a given channel.
-func (ss *session) SendMessage(channelID string, message string) {
-	_, err := ss.ChannelMessageSend(channelID, message)
+// discordgo. It will send a message to a given channel and return the
+// message that was sent. Messages over Discord's length limit are split
+// into several, in which case the first is returned, or sent as a .txt
+// attachment if they are longer than MessageAttachThreshold.
+func (ss *session) SendMessage(channelID string, message string) (*discordgo.Message, error) {
+	return ss.sendText(channelID, message, ss.mentions)
+}
+
+// SendEmbed is a helper function around ChannelMessageSendEmbed from
+// discordgo. It will send an embed message to a given channel and
+// return the message that was sent.
+func (ss *session) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return ss.sendEmbed(channelID, embed, ss.mentions)
+}
+
+// SendComplex is a helper function around ChannelMessageSendComplex
+// from discordgo. It will send a message with files to a given channel
+// and return the message that was sent. Messages with files are only
+// attempted once, since the files can't be read again.
+func (ss *session) SendComplex(channelID string, ms *discordgo.MessageSend) (*discordgo.Message, error) {
+	return ss.sendComplex(channelID, ms, ss.mentions)
+}
+
+func (ss *session) sendText(channelID, message string, am *AllowedMentions) (*discordgo.Message, error) {
+	if ss.attachThreshold > 0 && utf8.RuneCountInString(message) > ss.attachThreshold {
+		return ss.sendComplex(channelID, &discordgo.MessageSend{
+			Files: []*discordgo.File{{Name: "message.txt", ContentType: "text/plain", Reader: strings.NewReader(message)}},
+		}, am)
+	}
+
+	var first *discordgo.Message
+	for _, chunk := range splitMessage(message, messageLimit) {
+		ms := &discordgo.MessageSend{Content: chunk}
+		m, err := ss.queue.do(channelID, "text", chunk, true, func() (*discordgo.Message, error) {
+			return ss.backend.SendMessage(channelID, ms, am)
+		})
+		ss.metrics.observeSend("text", err)
+		if err != nil {
+			ss.logger.Error("Failed to send message response", "channel", channelID, "error", err)
+			return first, err
+		}
+		if first == nil {
+			first = m
+		}
+	}
+	return first, nil
+}
+
+func (ss *session) sendEmbed(channelID string, embed *discordgo.MessageEmbed, am *AllowedMentions) (*discordgo.Message, error) {
+	ms := &discordgo.MessageSend{Embed: embed}
+	m, err := ss.queue.do(channelID, "embed", embed, true, func() (*discordgo.Message, error) {
+		return ss.backend.SendMessage(channelID, ms, am)
+	})
+	ss.metrics.observeSend("embed", err)
 	if err != nil {
-		ss.logger.info("Failed to send message response -", err)
+		ss.logger.Error("Failed to send embed message response", "channel", channelID, "error", err)
 	}
+	return m, err
 }
 
-// SendEmbed is a helper function around ChannelMessageSendEmbed from
-// discordgo. It will send an embed message to a given channel.
-func (ss *session) SendEmbed(channelID string, embed *discordgo.MessageEmbed) {
-	_, err := ss.ChannelMessageSendEmbed(channelID, embed)
+func (ss *session) sendComplex(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error) {
+	m, err := ss.queue.do(channelID, "complex", ms, len(ms.Files) == 0, func() (*discordgo.Message, error) {
+		return ss.backend.SendMessage(channelID, ms, am)
+	})
+	ss.metrics.observeSend("complex", err)
+	if err != nil {
+		ss.logger.Error("Failed to send complex message response", "channel", channelID, "error", err)
+	}
+	return m, err
+}
+
+// sendMessageSend sends ms like sendComplex, except that content that
+// would be split or attached by sendText is sent through it first,
+// followed by a message with the embed and files.
+func (ss *session) sendMessageSend(channelID string, ms *discordgo.MessageSend, am *AllowedMentions) (*discordgo.Message, error) {
+	if ms.Embed == nil && len(ms.Files) == 0 {
+		return ss.sendText(channelID, ms.Content, am)
+	}
+	n := utf8.RuneCountInString(ms.Content)
+	if n <= messageLimit && (ss.attachThreshold <= 0 || n <= ss.attachThreshold) {
+		return ss.sendComplex(channelID, ms, am)
+	}
+
+	first, err := ss.sendText(channelID, ms.Content, am)
+	if err != nil {
+		return first, err
+	}
+	rest := *ms
+	rest.Content = ""
+	if _, err := ss.sendComplex(channelID, &rest, am); err != nil {
+		return first, err
+	}
+	return first, nil
+}
+
+// EditMessage is a helper function around ChannelMessageEdit from
+// discordgo. It will replace the content of a message the bot sent.
+func (ss *session) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	m, err := ss.backend.EditMessage(channelID, messageID, content)
+	if err != nil {
+		ss.logger.Error("Failed to edit message", "channel", channelID, "message", messageID, "error", err)
+	}
+	return m, err
+}
+
+// DeleteMessage is a helper function around ChannelMessageDelete from
+// discordgo. It will delete a message from a given channel.
+func (ss *session) DeleteMessage(channelID, messageID string) error {
+	err := ss.backend.DeleteMessage(channelID, messageID)
 	if err != nil {
-		ss.logger.error("Failed to send embed message response -", err)
+		ss.logger.Error("Failed to delete message", "channel", channelID, "message", messageID, "error", err)
 	}
+	return err
 }
 
-func (ss *session) SendComplex(channelID string, ms *discordgo.MessageSend) {
-	_, err := ss.ChannelMessageSendComplex(channelID, ms)
+// PinMessage is a helper function around ChannelMessagePin from
+// discordgo. It will pin a message in a given channel.
+func (ss *session) PinMessage(channelID, messageID string) error {
+	err := ss.backend.PinMessage(channelID, messageID)
 	if err != nil {
-		ss.logger.error("Failed to send complex message response -", err)
+		ss.logger.Error("Failed to pin message", "channel", channelID, "message", messageID, "error", err)
 	}
+	return err
 }
 
 // AddReaction is a helper method around MessageReactionAdd from
 // discordgo. It adds a reaction to a given message.
-func (ss *session) AddReaction(m *discordgo.MessageCreate, reactionID string) {
-	if err := ss.MessageReactionAdd(m.ChannelID, m.ID, reactionID); err != nil {
-		ss.logger.error("Error adding reaction -", err)
+func (ss *session) AddReaction(m *discordgo.MessageCreate, reactionID string) error {
+	err := ss.backend.AddReaction(m.ChannelID, m.ID, reactionID)
+	if err != nil {
+		ss.logger.Error("Error adding reaction", "channel", m.ChannelID, "message", m.ID, "error", err)
 	}
+	return err
 }
@@ -0,0 +1,146 @@
+package spudo
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Closes a code block left open at the end of a chunk.
+	closeFence = "\n```"
+	// Smallest limit that leaves room for a code block to be closed and
+	// reopened in every chunk. Below it code blocks are split like any
+	// other text.
+	minFenceLimit = 16
+)
+
+// splitMessage splits content into chunks of at most limit characters,
+// breaking at newlines where possible and otherwise between words. A
+// code block that is open at the end of a chunk is closed and reopened
+// at the start of the next one, so it renders the same in every
+// chunk.
+func splitMessage(content string, limit int) []string {
+	if limit < 1 {
+		limit = 1
+	}
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	fence := ""   // Opening line of the code block current is in, if any
+	reopened := 0 // Length of the fence current was started with
+	fences := limit >= minFenceLimit
+
+	flush := func() {
+		text := current.String()
+		if fence != "" {
+			text += closeFence
+		}
+		chunks = append(chunks, text)
+		current.Reset()
+		current.WriteString(fence)
+		reopened = utf8.RuneCountInString(fence)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		for {
+			used := utf8.RuneCountInString(current.String())
+			sep := 0
+			if used > 0 {
+				sep = 1
+			}
+			reserve := 0
+			if fences && (fence != "" || strings.Contains(line, "```")) {
+				reserve = len(closeFence)
+			}
+			avail := limit - used - sep - reserve
+
+			if utf8.RuneCountInString(line) <= avail {
+				if sep > 0 {
+					current.WriteString("\n")
+				}
+				current.WriteString(line)
+				if fences {
+					fence = nextFence(fence, line, limit)
+				}
+				break
+			}
+			if used > reopened {
+				flush()
+				continue
+			}
+
+			// The line doesn't fit in a chunk on its own, so it has to
+			// be broken up
+			if avail < 1 {
+				avail = 1
+			}
+			runes := []rune(line)
+			cut, next := avail, avail
+			// A space just past the limit is a boundary too
+			if i := lastSpace(runes[:avail+1]); i > 0 {
+				cut, next = i, i+1
+			} else {
+				// Don't break up a fence
+				for cut > 1 && runes[cut-1] == '`' && runes[cut] == '`' {
+					cut--
+				}
+				if runes[cut-1] == '`' && runes[cut] == '`' {
+					cut = avail
+				}
+				next = cut
+			}
+			if sep > 0 {
+				current.WriteString("\n")
+			}
+			piece := string(runes[:cut])
+			current.WriteString(piece)
+			if fences {
+				fence = nextFence(fence, piece, limit)
+			}
+			line = string(runes[next:])
+			flush()
+		}
+	}
+	if utf8.RuneCountInString(current.String()) > reopened {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// nextFence returns the opening line of the code block a chunk is in
+// after text is added to it, given it was in the block opened by fence
+// before. Only the language of a block is kept when it is reopened, and
+// not even that if it would take up too much of a chunk.
+func nextFence(fence, text string, limit int) string {
+	if !togglesFence(text) {
+		return fence
+	}
+	if fence != "" {
+		return ""
+	}
+	fence = "```"
+	if info := strings.Fields(text[strings.LastIndex(text, "```")+3:]); len(info) > 0 {
+		fence += info[0]
+	}
+	if utf8.RuneCountInString(fence) > limit/4 {
+		fence = "```"
+	}
+	return fence
+}
+
+// togglesFence reports whether line opens or closes a code block.
+func togglesFence(line string) bool {
+	return strings.Count(line, "```")%2 == 1
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
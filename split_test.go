@@ -0,0 +1,110 @@
+package spudo
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	if chunks := splitMessage("short", 20); len(chunks) != 1 || chunks[0] != "short" {
+		t.Errorf("Expected short message to be unchanged - got %q", chunks)
+	}
+
+	chunks := splitMessage("first line\nsecond line\nthird line", 25)
+	expected := []string{"first line\nsecond line", "third line"}
+	if strings.Join(chunks, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected split at newlines %q - got %q", expected, chunks)
+	}
+
+	chunks = splitMessage("one two three four five six", 10)
+	expected = []string{"one two", "three four", "five six"}
+	if strings.Join(chunks, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected split between words %q - got %q", expected, chunks)
+	}
+
+	chunks = splitMessage(strings.Repeat("x", 25), 10)
+	if len(chunks) != 3 || chunks[2] != "xxxxx" {
+		t.Errorf("Expected long word to be broken up - got %q", chunks)
+	}
+}
+
+func TestSplitMessageCodeBlock(t *testing.T) {
+	lines := []string{"Output:", "```go"}
+	for i := 0; i < 10; i++ {
+		lines = append(lines, "fmt.Println(\"hello\")")
+	}
+	lines = append(lines, "```", "Done")
+	chunks := splitMessage(strings.Join(lines, "\n"), 80)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected message to be split - got %q", chunks)
+	}
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 80 {
+			t.Errorf("Chunk %d is %d characters long", i, n)
+		}
+		if strings.Count(c, "```")%2 != 0 {
+			t.Errorf("Chunk %d leaves a code block open: %q", i, c)
+		}
+		if i > 0 && i < len(chunks)-1 && !strings.HasPrefix(c, "```go\n") {
+			t.Errorf("Expected chunk %d to reopen the code block: %q", i, c)
+		}
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasSuffix(last, "```\nDone") {
+		t.Errorf("Expected last chunk to close the code block: %q", last)
+	}
+}
+
+func TestSplitMessageFences(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		reopen  string // Fence every chunk but the first should start with
+	}{
+		{"opens mid line", "See: ```go fmt.Println(1)\n" + strings.Repeat("fmt.Println(2)\n", 6) + "```", 40, "```go\n"},
+		{"opens in a long line", "```py " + strings.Repeat("x", 60) + "\nprint(1)\n```", 30, "```py\n"},
+		{"long info string", "```" + strings.Repeat("y", 30) + "\n" + strings.Repeat("z ", 40) + "\n```", 40, "```\n"},
+		{"fence at the cut", strings.Repeat("a", 23) + "```" + strings.Repeat("b", 30) + "```", 25, ""},
+	}
+	for _, tt := range tests {
+		chunks := splitMessage(tt.content, tt.limit)
+		if len(chunks) < 2 {
+			t.Fatalf("%s: expected message to be split - got %q", tt.name, chunks)
+		}
+		for i, c := range chunks {
+			if n := utf8.RuneCountInString(c); n > tt.limit {
+				t.Errorf("%s: chunk %d is %d characters long: %q", tt.name, i, n, c)
+			}
+			if strings.Count(c, "```")%2 != 0 {
+				t.Errorf("%s: chunk %d leaves a code block open: %q", tt.name, i, c)
+			}
+			if i > 0 && tt.reopen != "" && !strings.HasPrefix(c, tt.reopen) {
+				t.Errorf("%s: expected chunk %d to start with %q: %q", tt.name, i, tt.reopen, c)
+			}
+		}
+	}
+}
+
+func TestSplitMessageSmallLimit(t *testing.T) {
+	contents := []string{
+		"one two three four five six",
+		"```go\nfmt.Println()\n```",
+		"``````` ``` `` ```\n\n\n```",
+		strings.Repeat("é", 40),
+	}
+	for _, content := range contents {
+		for limit := -1; limit <= minFenceLimit; limit++ {
+			chunks := splitMessage(content, limit)
+			if strings.Join(chunks, "") == "" {
+				t.Errorf("Expected %q at limit %d to be kept - got %q", content, limit, chunks)
+			}
+			for i, c := range chunks {
+				if n := utf8.RuneCountInString(c); limit > 0 && n > limit {
+					t.Errorf("Chunk %d of %q at limit %d is %d characters long", i, content, limit, n)
+				}
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package spudo
 
 import (
+	"database/sql"
 	"errors"
 	"flag"
+	"io"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -18,14 +21,41 @@ import (
 
 // Config contains all options for the config file
 type Config struct {
-	Token                 string
-	CommandPrefix         string
-	CooldownTimer         int
-	CooldownMessage       string
-	UnknownCommandMessage string
-	AudioEnabled          bool
-	RESTEnabled           bool
-	RESTPort              string
+	Token                  string
+	CommandPrefix          string
+	CooldownTimer          int
+	CooldownMessage        string
+	UnknownCommandMessage  string
+	AudioEnabled           bool
+	RESTEnabled            bool
+	RESTPort               string
+	RESTAddress            string
+	RESTReadTimeout        int
+	RESTWriteTimeout       int
+	RESTAPIKeys            []string
+	RESTSecrets            map[string]string
+	RESTAllowedIPs         []string
+	RESTTLSCert            string
+	RESTTLSKey             string
+	RESTClientCA           string
+	RESTManagementAPI      bool
+	Relays                 []RelayConfig
+	MetricsEnabled         bool
+	MetricsRequireAPIKey   bool
+	HealthEnabled          bool
+	RESTRateLimits         []RateLimitConfig
+	CoalesceWindow         int
+	MessageAttachThreshold int
+	SendAttempts           int
+	AllowedMentions        []string
+	TypingDelayMS          int
+	StorePath              string
+	StoreDriver            string
+	StoreDSN               string
+	LogLevel               string
+	LogFormat              string
+	LogFile                LogFileConfig
+	AuditLog               LogFileConfig
 }
 
 // Spudo contains everything about the bot itself
@@ -36,39 +66,91 @@ type Spudo struct {
 	Config        Config
 	CooldownList  map[string]time.Time
 	TimersStarted bool
-	logger        *spudoLogger
+	logger        Logger
+	logFile       io.Closer
+	audit         *auditLog
+	restRouter    *router
+	restServer    *http.Server
+	startTime     time.Time
+	metrics       *metrics
+	coalescer     *coalescer
 
 	spudoCommands map[string]*spudoCommand
 
-	commands         map[string]*command
-	startupPlugins   []*startupPlugin
-	timedMessages    []*timedMessage
-	userReactions    []*userReaction
-	messageReactions []*messageReaction
+	commands          map[string]*command
+	startupPlugins    []*lifecyclePlugin
+	everyReadyPlugins []*lifecyclePlugin
+	shutdownPlugins   []*lifecyclePlugin
+	reconnectPlugins  []*lifecyclePlugin
+	disconnectPlugins []*lifecyclePlugin
+	guildJoinPlugins  []*guildPlugin
+	guildLeavePlugins []*guildPlugin
+	timedMessages     []*timedMessage
+	userReactions     []*userReaction
+	messageReactions  []*messageReaction
+	eventPlugins      []*eventPlugin
+	handlersAdded     bool // Whether Connect has added the eventPlugins' handlers, guarded by the embedded Mutex
+	healthChecks      []*healthCheck
+	deadLetterPlugins []*deadLetterPlugin
+
+	// Gateway lifecycle state, guarded by the embedded Mutex
+	startupDone   bool
+	connected     bool
+	disconnected  bool
+	restListening bool
+	shuttingDown  bool
+	knownGuilds   map[string]bool
 
 	audioSessions map[string]*spAudio
+	webhooks      map[string]*discordgo.Webhook
+	webhookLocks  map[string]*sync.Mutex
+	console       *consoleBackend
+	storage       Store
+	storeDB       *sql.DB
+	migrations    []*migration
+	migrated      bool
+	crons         []*cron.Cron
 }
 
 type unknownCommand string
 
+var (
+	errUnsupportedMessage = errors.New("unsupported message type")
+	errNoToken            = errors.New("no token in config")
+)
+
 // Initialize will initialize everything Spudo needs to run.
 func Initialize() *Spudo {
 	sp := newSpudo()
 
 	configPath := flag.String("config", "./config.toml", "TODO")
+	console := flag.Bool("console", false, "run the bot against messages typed into stdin instead of Discord")
+	consoleUser := flag.String("console-user", "console-user", "ID of the user console messages are sent as")
+	consoleChannel := flag.String("console-channel", "console-channel", "ID of the channel console messages are sent to")
+	consoleGuild := flag.String("console-guild", "console-guild", "ID of the guild console messages are sent to")
 	flag.Parse()
 
 	// Check if config exists, if it doesn't use
 	// createMinimalConfig to generate one.
 	if _, err := os.Stat(*configPath); os.IsNotExist(err) {
-		sp.logger.info("Config not detected, creating minimal config...")
+		sp.logger.Info("Config not detected, creating minimal config...")
 		if err := sp.createMinimalConfig(); err != nil {
-			sp.logger.fatal("Failed to create minimal config", err)
+			sp.fatal("Failed to create minimal config", "error", err)
 		}
 	}
 
-	if err := sp.loadConfig(*configPath); err != nil {
-		sp.logger.fatal(err.Error())
+	// The console doesn't connect to Discord, so it doesn't need a token
+	if err := sp.loadConfig(*configPath); err != nil && !(*console && err == errNoToken) {
+		sp.fatal(err.Error())
+	}
+
+	if err := sp.configureLogging(); err != nil {
+		sp.fatal(err.Error())
+	}
+
+	if *console {
+		sp.console = newConsoleBackend(os.Stdin, os.Stdout, *consoleUser, *consoleChannel, *consoleGuild)
+		sp.SetBackend(sp.console)
 	}
 
 	return sp
@@ -84,6 +166,14 @@ func newSpudo() *Spudo {
 	sp.userReactions = make([]*userReaction, 0)
 	sp.messageReactions = make([]*messageReaction, 0)
 	sp.spudoCommands = make(map[string]*spudoCommand)
+	sp.knownGuilds = make(map[string]bool)
+	sp.webhooks = make(map[string]*discordgo.Webhook)
+	sp.webhookLocks = make(map[string]*sync.Mutex)
+	sp.restRouter = newRouter()
+	sp.metrics = newMetrics()
+	sp.coalescer = newCoalescer(0, func(channelID, message string) {
+		sp.SendMessage(channelID, message)
+	})
 	return sp
 }
 
@@ -95,6 +185,15 @@ func getDefaultConfig() Config {
 		CooldownTimer:         10,
 		CooldownMessage:       "Too many commands at once!",
 		UnknownCommandMessage: "Invalid command!",
+		RESTReadTimeout:       10,
+		RESTWriteTimeout:      10,
+		CoalesceWindow:        2,
+		SendAttempts:          3,
+		AllowedMentions:       []string{MentionUsers},
+		TypingDelayMS:         1000,
+		StorePath:             "./store.json",
+		LogLevel:              "info",
+		LogFormat:             "text",
 	}
 }
 
@@ -126,116 +225,391 @@ func (sp *Spudo) loadConfig(configPath string) error {
 	}
 
 	if sp.Config.Token == "" {
-		return errors.New("no token in config")
+		return errNoToken
+	}
+	return nil
+}
+
+// configureLogging sets up the logger and audit log based on the
+// Config. Logs are written to stdout, and also to LogFile if a path is
+// set.
+func (sp *Spudo) configureLogging() error {
+	var w io.Writer = os.Stdout
+	if sp.Config.LogFile.Path != "" {
+		f, err := newRotatingFile(sp.Config.LogFile)
+		if err != nil {
+			return errors.New("Failed to open log file - " + err.Error())
+		}
+		sp.logFile = f
+		w = io.MultiWriter(os.Stdout, f)
+	}
+
+	logger, err := newConfigLogger(sp.Config, w)
+	if err != nil {
+		return errors.New("Failed to configure logger - " + err.Error())
+	}
+	sp.logger = logger
+
+	if sp.Config.AuditLog.Path != "" {
+		f, err := newRotatingFile(sp.Config.AuditLog)
+		if err != nil {
+			return errors.New("Failed to open audit log - " + err.Error())
+		}
+		sp.audit = newAuditLog(f)
 	}
 	return nil
 }
 
+// New creates a bot using config, rather than reading it from a file
+// like Initialize. Fields that aren't set in config are left empty, so
+// it is usually based on DefaultConfig.
+func New(config Config) (*Spudo, error) {
+	sp := newSpudo()
+	sp.Config = config
+	if err := sp.configureLogging(); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// DefaultConfig returns the settings used for anything not set in the
+// config file.
+func DefaultConfig() Config {
+	return getDefaultConfig()
+}
+
+// SetBackend replaces the connection to Discord with b. It must be
+// called before Start or Connect. The embedded discordgo Session is
+// left nil, so only the bot's own methods such as SendMessage can be
+// used with another backend.
+func (sp *Spudo) SetBackend(b Backend) {
+	sp.session = &session{backend: b}
+}
+
 // Start will add handler functions to the Session and open the
 // websocket connection
 func (sp *Spudo) Start() {
+	if err := sp.Connect(); err != nil {
+		sp.fatal(err.Error())
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+
+	if sp.console != nil {
+		sp.logger.Info("Bot is now running in console mode. Type messages to send them to the bot, press CTRL-D to exit.")
+		select {
+		case <-c:
+		case <-sp.console.readInput():
+		}
+	} else {
+		sp.logger.Info("Bot is now running. Press CTRL-C to exit.")
+		<-c
+	}
+
+	sp.quit()
+}
+
+// Connect does everything Start does to get the bot running, but
+// returns instead of waiting for the process to be stopped. Shutdown
+// stops the bot again.
+func (sp *Spudo) Connect() error {
 	rand.Seed(time.Now().UnixNano())
+	sp.startTime = time.Now()
 
-	var err error
-	if sp.session, err = newSession(sp.Config.Token, sp.logger); err != nil {
-		sp.logger.fatal(err.Error())
+	if sp.session == nil {
+		ss, err := newSession(sp.Config.Token, sp.logger)
+		if err != nil {
+			return errors.New("Error creating discord session - " + err.Error())
+		}
+		sp.session = ss
+	}
+	sp.session.logger = sp.logger
+	sp.session.metrics = sp.metrics
+	sp.session.attachThreshold = sp.Config.MessageAttachThreshold
+	sp.session.mentions = &AllowedMentions{Parse: sp.Config.AllowedMentions}
+	sp.session.queue = newSendQueue(sp.Config.SendAttempts, sp.logger, sp.metrics, sp.runDeadLetterPlugins)
+	sp.coalescer.window = time.Duration(sp.Config.CoalesceWindow) * time.Second
+
+	if err := sp.openStore(); err != nil {
+		return errors.New("Error opening store - " + err.Error())
 	}
 
 	if sp.Config.AudioEnabled {
 		sp.addAudioCommands()
 		sp.audioSessions = make(map[string]*spAudio)
 		go sp.watchForDisconnect()
-		sp.logger.info("Audio commands added")
+		sp.logger.Info("Audio commands added")
 	}
 
 	if sp.Config.RESTEnabled {
-		go sp.startRESTApi()
+		if sp.Config.RESTManagementAPI {
+			sp.addManagementRoutes()
+		}
+		sp.addRelays()
+		if sp.Config.MetricsEnabled {
+			sp.addMetricsRoute()
+		}
+		if sp.Config.HealthEnabled {
+			sp.addHealthRoutes()
+		}
+		if err := sp.startRESTApi(); err != nil {
+			return errors.New("Error starting REST API - " + err.Error())
+		}
 	}
 
-	sp.AddHandler(sp.onReady)
-	sp.AddHandler(sp.onMessageCreate)
-
-	if err := sp.Open(); err != nil {
-		sp.logger.fatal("Error opening websocket connection -", err)
+	sp.backend.AddHandler(sp.onReady)
+	sp.backend.AddHandler(sp.onResumed)
+	sp.backend.AddHandler(sp.onDisconnect)
+	sp.backend.AddHandler(sp.onGuildCreate)
+	sp.backend.AddHandler(sp.onGuildDelete)
+	sp.backend.AddHandler(sp.onMessageCreate)
+	sp.Lock()
+	for _, p := range sp.eventPlugins {
+		sp.backend.AddHandler(p.Handler)
 	}
+	sp.handlersAdded = true
+	sp.Unlock()
 
-	sp.logger.info("Bot is now running. Press CTRL-C to exit.")
-
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-	<-c
-
-	sp.quit()
+	if err := sp.backend.Open(); err != nil {
+		return errors.New("Error opening websocket connection - " + err.Error())
+	}
+	return nil
 }
 
 // quit handles everything that needs to occur for the bot to shutdown cleanly.
 func (sp *Spudo) quit() {
-	sp.logger.info("Bot is now shutting down")
-	for _, as := range sp.audioSessions {
-		if err := as.Voice.Disconnect(); err != nil {
-			sp.logger.fatal("Error disconnecting from voice channel:", err)
+	sp.Shutdown()
+	os.Exit(1)
+}
+
+// Shutdown runs the shutdown plugins, then stops the timed messages,
+// waits for queued messages to be sent, disconnects from Discord and
+// stops the REST API.
+func (sp *Spudo) Shutdown() {
+	sp.logger.Info("Bot is now shutting down")
+	sp.Lock()
+	sp.shuttingDown = true
+	crons := sp.crons
+	sp.crons = nil
+	sp.Unlock()
+	sp.runLifecyclePlugins(sp.shutdownPlugins)
+	sp.stopRESTApi()
+	for _, c := range crons {
+		// Waits for timed messages that are running to finish
+		<-c.Stop().Done()
+	}
+	// The session is only set once Connect gets that far
+	if sp.session != nil {
+		sp.coalescer.flush()
+		sp.queue.stop()
+
+		for _, as := range sp.audioSessions {
+			if err := as.Voice.Disconnect(); err != nil {
+				sp.logger.Error("Error disconnecting from voice channel", "error", err)
+			}
+		}
+		if err := sp.backend.Close(); err != nil {
+			sp.logger.Error("Error closing discord session", "error", err)
 		}
 	}
-	if err := sp.Close(); err != nil {
-		sp.logger.fatal("Error closing discord session:", err)
+	if sp.storeDB != nil {
+		if err := sp.storeDB.Close(); err != nil {
+			sp.logger.Error("Error closing store database", "error", err)
+		}
+	}
+	if err := sp.audit.Close(); err != nil {
+		sp.logger.Error("Error closing audit log", "error", err)
+	}
+	if sp.logFile != nil {
+		sp.logFile.Close()
 	}
-	os.Exit(1)
 }
 
 func (sp *Spudo) onReady(s *discordgo.Session, r *discordgo.Ready) {
-	for _, p := range sp.startupPlugins {
-		p.Exec()
+	sp.Lock()
+	firstReady := !sp.startupDone
+	reconnected := sp.disconnected
+	sp.startupDone = true
+	sp.connected = true
+	sp.disconnected = false
+	for _, g := range r.Guilds {
+		sp.knownGuilds[g.ID] = true
 	}
+	sp.Unlock()
+
+	if firstReady {
+		sp.runLifecyclePlugins(sp.startupPlugins)
+	} else if reconnected {
+		sp.runLifecyclePlugins(sp.reconnectPlugins)
+	}
+	sp.runLifecyclePlugins(sp.everyReadyPlugins)
 
 	if !sp.TimersStarted {
 		sp.startTimedMessages()
 	}
 }
 
+// onResumed handles the gateway resuming a session after a
+// disconnect, which does not produce a new Ready event.
+func (sp *Spudo) onResumed(s *discordgo.Session, r *discordgo.Resumed) {
+	sp.Lock()
+	reconnected := sp.disconnected
+	sp.connected = true
+	sp.disconnected = false
+	sp.Unlock()
+
+	if reconnected {
+		sp.runLifecyclePlugins(sp.reconnectPlugins)
+	}
+}
+
+func (sp *Spudo) onDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	sp.Lock()
+	alreadyDisconnected := sp.disconnected || sp.shuttingDown
+	sp.connected = false
+	sp.disconnected = true
+	sp.Unlock()
+
+	if !alreadyDisconnected {
+		sp.runLifecyclePlugins(sp.disconnectPlugins)
+	}
+}
+
+// onGuildCreate is sent for every guild after Ready as well as when
+// the bot joins a guild, so only guilds that haven't been seen before
+// are treated as a join.
+func (sp *Spudo) onGuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if g.Unavailable {
+		return
+	}
+	sp.Lock()
+	known := sp.knownGuilds[g.ID]
+	sp.knownGuilds[g.ID] = true
+	sp.Unlock()
+
+	if known {
+		return
+	}
+	for _, p := range sp.guildJoinPlugins {
+		p := p
+		sp.runPlugin(p.Name, func() { p.Exec(g.Guild) })
+	}
+}
+
+// onGuildDelete is sent when the bot is removed from a guild or when
+// a guild becomes unavailable, in which case Unavailable is set.
+func (sp *Spudo) onGuildDelete(s *discordgo.Session, g *discordgo.GuildDelete) {
+	if g.Unavailable {
+		return
+	}
+	sp.Lock()
+	delete(sp.knownGuilds, g.ID)
+	sp.Unlock()
+
+	for _, p := range sp.guildLeavePlugins {
+		p := p
+		sp.runPlugin(p.Name, func() { p.Exec(g.Guild) })
+	}
+}
+
+func (sp *Spudo) runLifecyclePlugins(plugins []*lifecyclePlugin) {
+	for _, p := range plugins {
+		sp.runPlugin(p.Name, p.Exec)
+	}
+}
+
+// runPlugin executes exec, recovering and logging a panic so one
+// plugin can't stop the ones after it, or the rest of Shutdown, from
+// running.
+func (sp *Spudo) runPlugin(name string, exec func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			sp.logger.Error("Plugin panicked", "plugin", name, "panic", r)
+		}
+	}()
+	exec()
+}
+
+// runDeadLetterPlugins passes a message that could not be sent to
+// every dead letter plugin.
+func (sp *Spudo) runDeadLetterPlugins(dl *DeadLetter) {
+	for _, p := range sp.deadLetterPlugins {
+		p.Exec(dl)
+	}
+}
+
 func (sp *Spudo) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Always ignore bot users (including itself)
 	if m.Author.Bot {
 		return
 	}
 
-	go sp.handleCommand(m)
-	go sp.handleUserReaction(m)
-	go sp.handleMessageReaction(m)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	for _, handle := range []func(*discordgo.MessageCreate){sp.handleCommand, sp.handleUserReaction, sp.handleMessageReaction} {
+		handle := handle
+		go func() {
+			defer wg.Done()
+			handle(m)
+		}()
+	}
+	wg.Wait()
 }
 
 // sendPrivateMessage creates a UserChannel before attempting to send
 // a message directly to a user rather than in the server channel.
-func (sp *Spudo) sendPrivateMessage(userID string, message interface{}) {
-	privChannel, err := sp.UserChannelCreate(userID)
+// message can be anything sendTo accepts, or a *discordgo.MessageEmbed.
+func (sp *Spudo) sendPrivateMessage(userID string, message interface{}) (*discordgo.Message, error) {
+	privChannel, err := sp.backend.CreateDM(userID)
 	if err != nil {
-		sp.logger.error("Error creating private channel -", err)
-		return
+		sp.logger.Error("Error creating private channel", "user", userID, "error", err)
+		return nil, err
 	}
-	switch v := message.(type) {
-	case string:
-		sp.SendMessage(privChannel.ID, v)
-	case *discordgo.MessageEmbed:
-		sp.SendEmbed(privChannel.ID, v)
+	if e, ok := message.(*discordgo.MessageEmbed); ok {
+		return sp.SendEmbed(privChannel.ID, e)
 	}
+	return sp.sendTo(privChannel.ID, message)
+}
+
+// sendTo sends message to channelID based on its type. message can be
+// a string, *Embed, *Complex or *MentionResponse.
+func (sp *Spudo) sendTo(channelID string, message interface{}) (*discordgo.Message, error) {
+	if mr, ok := message.(*MentionResponse); ok {
+		return sp.SendWithMentions(channelID, mr.Message, mr.AllowedMentions)
+	}
+	return sp.SendWithMentions(channelID, message, sp.mentions)
 }
 
 // respondToUser is a helper method around SendMessage that will
-// mention the user who created the message.
-func (sp *Spudo) respondToUser(m *discordgo.MessageCreate, response string) {
-	sp.SendMessage(m.ChannelID, m.Author.Mention()+" "+response)
+// mention the user who created the message. The user is pinged even if
+// the default AllowedMentions don't allow user mentions.
+func (sp *Spudo) respondToUser(m *discordgo.MessageCreate, response string) (*discordgo.Message, error) {
+	return sp.sendText(m.ChannelID, m.Author.Mention()+" "+response, sp.mentions.withUser(m.Author.ID))
 }
 
 // attemptCommand will check if comStr is in the commands map. If it
 // is, it will return the command response as resp and whether or not
 // the message should be sent privately as private.
-func (sp *Spudo) attemptCommand(author, channel, comStr string, args []string) (resp interface{}, private bool) {
+func (sp *Spudo) attemptCommand(m *discordgo.MessageCreate, comStr string, args []string) (resp interface{}, private bool) {
 	if com, isValid := sp.spudoCommands[comStr]; isValid {
-		resp = com.Exec(author, channel, args...)
+		resp = com.Exec(m.Author.ID, m.ChannelID, args...)
 		return
 	}
 
 	if com, isValid := sp.commands[comStr]; isValid {
-		resp = com.Exec(author, args)
+		if com.ExecContext != nil {
+			resp = com.ExecContext(&EventContext{
+				sp:        sp,
+				Plugin:    com.Name,
+				GuildID:   m.GuildID,
+				ChannelID: m.ChannelID,
+				UserID:    m.Author.ID,
+				MessageID: m.ID,
+			}, args)
+		} else {
+			resp = com.Exec(m.Author.ID, args)
+		}
 		private = com.PrivateResponse
 		return
 	}
@@ -246,17 +620,33 @@ func (sp *Spudo) handleCommand(m *discordgo.MessageCreate) {
 	if !strings.HasPrefix(m.Content, sp.Config.CommandPrefix) {
 		return
 	}
-	if !sp.canPost(m.Author.ID) {
-		sp.respondToUser(m, sp.Config.CooldownMessage)
-		return
-	}
+	start := time.Now()
 
 	commandText := strings.Split(strings.TrimPrefix(m.Content, sp.Config.CommandPrefix), " ")
 
 	com := strings.ToLower(commandText[0])
 	args := commandText[1:]
 
-	commandResp, isPrivate := sp.attemptCommand(m.Author.ID, m.ChannelID, com, args)
+	outcome := outcomeOK
+	defer func() {
+		if r := recover(); r != nil {
+			outcome = outcomePanic
+			sp.logger.Error("Command panicked", "command", com, "panic", r)
+		}
+		latency := time.Since(start)
+		sp.auditCommand(m, com, args, outcome, latency)
+		sp.metrics.observeCommand(com, outcome, latency)
+	}()
+
+	if !sp.canPost(m.Author.ID) {
+		outcome = outcomeCooldown
+		sp.respondToUser(m, sp.Config.CooldownMessage)
+		return
+	}
+
+	stopTyping := sp.startTyping(m.ChannelID, com)
+	defer stopTyping()
+	commandResp, isPrivate := sp.attemptCommand(m, com, args)
 
 	switch v := commandResp.(type) {
 	case nil: // For commands that do not need a response
@@ -278,13 +668,47 @@ func (sp *Spudo) handleCommand(m *discordgo.MessageCreate) {
 		defer v.file.Close()
 		sp.SendComplex(m.ChannelID, v.MessageSend)
 		sp.startCooldown(m.Author.ID)
+	case *MentionResponse:
+		if isPrivate {
+			sp.sendPrivateMessage(m.Author.ID, v)
+		} else {
+			sp.respondWith(m, v)
+		}
+		sp.startCooldown(m.Author.ID)
+	case *Deferred:
+		sp.startCooldown(m.Author.ID)
+		sp.runDeferred(sp.newReply(m, isPrivate), v, stopTyping)
+	case <-chan interface{}:
+		sp.startCooldown(m.Author.ID)
+		sp.streamResponses(sp.newReply(m, isPrivate), v, stopTyping)
+	case chan interface{}:
+		sp.startCooldown(m.Author.ID)
+		sp.streamResponses(sp.newReply(m, isPrivate), v, stopTyping)
 	case voiceCommand:
 		sp.SendMessage(m.ChannelID, string(v))
 	case unknownCommand:
+		outcome = outcomeUnknown
 		sp.respondToUser(m, string(v))
 	}
 }
 
+// auditCommand records a command invocation in the audit log.
+func (sp *Spudo) auditCommand(m *discordgo.MessageCreate, com string, args []string, outcome string, latency time.Duration) {
+	err := sp.audit.record(&auditEntry{
+		Time:      time.Now().UTC(),
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		UserID:    m.Author.ID,
+		Command:   com,
+		Args:      args,
+		Outcome:   outcome,
+		LatencyMS: float64(latency) / float64(time.Millisecond),
+	})
+	if err != nil {
+		sp.logger.Error("Error writing audit log", "error", err)
+	}
+}
+
 func (sp *Spudo) handleUserReaction(m *discordgo.MessageCreate) {
 	for _, ur := range sp.userReactions {
 		for _, user := range ur.UserIDs {
@@ -325,26 +749,62 @@ func (sp *Spudo) startCooldown(user string) {
 // Starts all TimedMessages.
 func (sp *Spudo) startTimedMessages() {
 	for _, p := range sp.timedMessages {
+		p := p
 		c := cron.New(cron.WithLocation(time.UTC))
 
 		if _, err := c.AddFunc(p.CronString, func() {
-			timerFunc := p.Exec()
-			switch v := timerFunc.(type) {
-			case string:
-				for _, chanID := range p.Channels {
-					sp.SendMessage(chanID, v)
-				}
-			case *Embed:
-				for _, chanID := range p.Channels {
-					sp.SendEmbed(chanID, v.MessageEmbed)
-				}
-			}
+			sp.runTimedMessage(p)
 		}); err != nil {
-			sp.logger.error("Error starting "+p.Name+" timed message - ", err)
+			sp.logger.Error("Error starting timed message", "plugin", p.Name, "error", err)
 			continue
 		}
 		c.Start()
+		sp.Lock()
+		sp.crons = append(sp.crons, c)
+		sp.Unlock()
 	}
 
 	sp.TimersStarted = true
 }
+
+// runTimedMessage executes a timed message and sends the result to
+// its channels.
+func (sp *Spudo) runTimedMessage(p *timedMessage) {
+	failed := false
+	defer func() {
+		if r := recover(); r != nil {
+			failed = true
+			sp.logger.Error("Timed message panicked", "plugin", p.Name, "panic", r)
+		}
+		sp.metrics.observeTimedMessage(p.Name, failed)
+	}()
+
+	timerFunc := p.Exec()
+	if p.Webhook != nil {
+		if timerFunc == nil {
+			return
+		}
+		for _, chanID := range p.Channels {
+			if _, err := sp.SendWebhookMessage(chanID, timerFunc, *p.Webhook); err != nil {
+				failed = true
+			}
+		}
+		return
+	}
+	for _, chanID := range p.Channels {
+		var err error
+		switch v := timerFunc.(type) {
+		case string:
+			_, err = sp.SendMessage(chanID, v)
+		case *Embed:
+			_, err = sp.SendEmbed(chanID, v.MessageEmbed)
+		case *MentionResponse:
+			_, err = sp.SendWithMentions(chanID, v.Message, v.AllowedMentions)
+		default:
+			return
+		}
+		if err != nil {
+			failed = true
+		}
+	}
+}
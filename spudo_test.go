@@ -1,9 +1,46 @@
 package spudo
 
 import (
+	"bytes"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/anorb/spudo/internal/fake"
+	"github.com/bwmarrin/discordgo"
 )
 
+// newTestBot returns a bot connected to a console backend that writes
+// to the returned buffer. setup, if not nil, is run before connecting to
+// add plugins or change the config.
+func newTestBot(t *testing.T, setup func(sp *Spudo)) (*Spudo, *consoleBackend, *bytes.Buffer) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	sp := newSpudo()
+	sp.Config = getDefaultConfig()
+	sp.Config.StorePath = ""
+	cb := newConsoleBackend(strings.NewReader(""), out, "user", "channel", "guild")
+	sp.SetBackend(cb)
+	if setup != nil {
+		setup(sp)
+	}
+	if err := sp.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return sp, cb, out
+}
+
+// commandMessage returns a message with content from "user" in
+// "channel".
+func commandMessage(content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: "channel",
+		Content:   content,
+		Author:    &discordgo.User{ID: "user"},
+	}}
+}
+
 func TestLoadConfig(t *testing.T) {
 	bot := newSpudo()
 	if err := bot.loadConfig("./examples/bot/config.toml"); err != nil {
@@ -20,3 +57,113 @@ func TestCreateSession(t *testing.T) {
 		t.Errorf("Error creating session - %s", err.Error())
 	}
 }
+
+func TestShutdownStopsTimedMessages(t *testing.T) {
+	var runs int32
+	bot, _, _ := newTestBot(t, func(bot *Spudo) {
+		bot.AddTimedMessage("tick", "@every 1s", []string{"channel"}, func() interface{} {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+	})
+	if len(bot.crons) != 1 {
+		t.Fatalf("Expected the timed message to be started - got %d crons", len(bot.crons))
+	}
+
+	bot.Shutdown()
+	if len(bot.crons) != 0 {
+		t.Errorf("Expected crons to be stopped - got %d", len(bot.crons))
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 0 {
+		t.Errorf("Expected the timed message not to run after shutdown - ran %d times", n)
+	}
+}
+
+func TestLifecyclePlugins(t *testing.T) {
+	counts := make(map[string]int)
+	var joined, left []string
+	sp, cb, _ := newTestBot(t, func(sp *Spudo) {
+		sp.AddStartupPlugin("startup", func() { counts["startup"]++ })
+		sp.OnEveryReady("ready", func() { counts["ready"]++ })
+		sp.OnReconnect("reconnect", func() { counts["reconnect"]++ })
+		sp.OnDisconnect("disconnect", func() { counts["disconnect"]++ })
+	})
+	defer sp.Shutdown()
+
+	steps := []struct {
+		name  string
+		event interface{}
+		want  map[string]int
+	}{
+		{"disconnect", &discordgo.Disconnect{}, map[string]int{"startup": 1, "ready": 1, "disconnect": 1}},
+		{"second disconnect", &discordgo.Disconnect{}, map[string]int{"startup": 1, "ready": 1, "disconnect": 1}},
+		{"ready after outage", fake.Ready(cb.state), map[string]int{"startup": 1, "ready": 2, "disconnect": 1, "reconnect": 1}},
+		{"ready without outage", fake.Ready(cb.state), map[string]int{"startup": 1, "ready": 3, "disconnect": 1, "reconnect": 1}},
+		{"disconnect", &discordgo.Disconnect{}, map[string]int{"startup": 1, "ready": 3, "disconnect": 2, "reconnect": 1}},
+		{"resumed after outage", &discordgo.Resumed{}, map[string]int{"startup": 1, "ready": 3, "disconnect": 2, "reconnect": 2}},
+		{"resumed without outage", &discordgo.Resumed{}, map[string]int{"startup": 1, "ready": 3, "disconnect": 2, "reconnect": 2}},
+	}
+	// Connecting dispatched the first Ready
+	if counts["startup"] != 1 || counts["ready"] != 1 || len(counts) != 2 {
+		t.Fatalf("Expected startup and ready plugins to run on connecting - got %v", counts)
+	}
+	for _, step := range steps {
+		cb.handlers.Dispatch(step.event)
+		if len(counts) != len(step.want) {
+			t.Fatalf("%s: expected %v - got %v", step.name, step.want, counts)
+		}
+		for name, n := range step.want {
+			if counts[name] != n {
+				t.Fatalf("%s: expected %v - got %v", step.name, step.want, counts)
+			}
+		}
+	}
+
+	sp.OnGuildJoin("join", func(g *discordgo.Guild) { joined = append(joined, g.ID) })
+	sp.OnGuildLeave("leave", func(g *discordgo.Guild) { left = append(left, g.ID) })
+	for _, event := range []interface{}{
+		&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "guild"}},
+		&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "new"}},
+		&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "new"}},
+		&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "outage", Unavailable: true}},
+		&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "new", Unavailable: true}},
+		&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "new"}},
+		&discordgo.GuildCreate{Guild: &discordgo.Guild{ID: "new"}},
+	} {
+		cb.handlers.Dispatch(event)
+	}
+	if strings.Join(joined, ",") != "new,new" {
+		t.Errorf("Expected join to run only for guilds that are new to the bot - got %v", joined)
+	}
+	if strings.Join(left, ",") != "new" {
+		t.Errorf("Expected leave to run only when the bot is removed - got %v", left)
+	}
+}
+
+func TestShutdownPluginPanic(t *testing.T) {
+	ran := false
+	sp, _, _ := newTestBot(t, func(sp *Spudo) {
+		sp.AddShutdownPlugin("broken", func() { panic("oops") })
+		sp.AddShutdownPlugin("working", func() { ran = true })
+	})
+	sp.Shutdown()
+
+	if !ran {
+		t.Error("Expected shutdown plugins after a panicking one to still run")
+	}
+	if !sp.queue.stopping() {
+		t.Error("Expected Shutdown to finish after a plugin panicked")
+	}
+}
+
+func TestShutdownBeforeConnect(t *testing.T) {
+	ran := false
+	sp := newSpudo()
+	sp.AddShutdownPlugin("cleanup", func() { ran = true })
+	sp.Shutdown()
+
+	if !ran {
+		t.Error("Expected shutdown plugins to run without a session")
+	}
+}
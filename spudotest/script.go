@@ -0,0 +1,189 @@
+package spudotest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/anorb/spudo"
+	"github.com/anorb/spudo/internal/fake"
+)
+
+var update = flag.Bool("spudotest.update", false, "rewrite the expected output in scripts run by RunScript")
+
+// step is a message sent to the bot in a script, along with the other
+// lines of the script that belong to it.
+type step struct {
+	lineNum int    // Line number of the input, 0 for the startup step
+	channel string // Channel the message is sent to
+	user    string // User the message is sent by
+	content string
+	lines   []string // Input and comment lines, without expected output
+	at      int      // Index in lines where the output goes
+	want    []string // Expected output, without the "< " prefix
+}
+
+// RunScript connects bot, plays the conversation in the script at path
+// and shuts it down again, failing t if the bot doesn't respond as the
+// script expects. Running the test with -spudotest.update rewrites the
+// script with what the bot actually did.
+//
+// Each line of a script starting with "> " is a message sent to the
+// bot, written as "> #channel user: content". The lines starting with
+// "< " after it are what the bot is expected to do in response:
+//
+//	> #general alice: !ping
+//	< #general: <@alice> Pong!
+//	< #general react 1: 👌
+//
+// Output before the first message comes from connecting, such as from
+// startup plugins, and output after it includes shutdown plugins.
+// Newlines in text are written as \n. Reactions are listed after
+// everything else and sorted, since they are added concurrently. Lines
+// starting with "#" and blank lines are comments.
+func RunScript(t *testing.T, bot *spudo.Spudo, session *Session, path string) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading script - %s", err)
+	}
+	steps, err := parseScript(string(data))
+	if err != nil {
+		t.Fatalf("Error parsing script %s - %s", path, err)
+	}
+
+	session.takeTranscript()
+	if err := bot.Connect(); err != nil {
+		t.Fatalf("Error connecting bot - %s", err)
+	}
+	got := make([][]string, len(steps))
+	got[0] = session.takeTranscript()
+	for i, s := range steps[1:] {
+		session.SendMessageCreate(s.channel, s.user, s.content)
+		got[i+1] = session.takeTranscript()
+	}
+	bot.Shutdown()
+	last := len(steps) - 1
+	got[last] = append(got[last], session.takeTranscript()...)
+
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(formatScript(steps, got)), 0644); err != nil {
+			t.Fatalf("Error updating script - %s", err)
+		}
+		return
+	}
+
+	for i, s := range steps {
+		if strings.Join(s.want, "\n") == strings.Join(got[i], "\n") {
+			continue
+		}
+		where := "on startup"
+		if s.lineNum > 0 {
+			where = fmt.Sprintf("for line %d", s.lineNum)
+		}
+		t.Errorf("%s: unexpected output %s\nwant:\n%s\ngot:\n%s", path, where, indent(s.want), indent(got[i]))
+	}
+}
+
+// parseScript splits script into steps. The first step holds anything
+// before the first message.
+func parseScript(script string) ([]*step, error) {
+	current := &step{at: -1}
+	steps := []*step{current}
+	for i, line := range strings.Split(strings.TrimSuffix(script, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "> "):
+			current = &step{lineNum: i + 1, lines: []string{line}, at: -1}
+			if err := parseInput(current, strings.TrimPrefix(line, "> ")); err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			steps = append(steps, current)
+		case strings.HasPrefix(line, "< "), line == "<":
+			if current.at < 0 {
+				current.at = len(current.lines)
+			}
+			current.want = append(current.want, strings.TrimPrefix(strings.TrimPrefix(line, "<"), " "))
+		case line == "" || strings.HasPrefix(line, "#"):
+			current.lines = append(current.lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: expected a message, output or comment", i+1)
+		}
+	}
+	return steps, nil
+}
+
+// parseInput reads a message written as "#channel user: content" into s.
+func parseInput(s *step, input string) error {
+	errFormat := fmt.Errorf("expected a message like \"> #channel user: content\"")
+	sep := strings.Index(input, ": ")
+	if sep < 0 {
+		return errFormat
+	}
+	from := strings.Fields(input[:sep])
+	if len(from) != 2 || !strings.HasPrefix(from[0], "#") {
+		return errFormat
+	}
+	s.channel = strings.TrimPrefix(from[0], "#")
+	s.user = from[1]
+	s.content = strings.Replace(input[sep+2:], `\n`, "\n", -1)
+	return nil
+}
+
+// formatScript writes steps back out with got as their output. Output
+// replaces the old output where it was, or follows the message if
+// there wasn't any.
+func formatScript(steps []*step, got [][]string) string {
+	var b strings.Builder
+	for i, s := range steps {
+		at := s.at
+		if at < 0 {
+			at = len(s.lines)
+			if s.lineNum > 0 {
+				at = 1
+			}
+		}
+		for j := 0; j <= len(s.lines); j++ {
+			if j == at {
+				for _, line := range got[i] {
+					b.WriteString("< " + line + "\n")
+				}
+			}
+			if j < len(s.lines) {
+				b.WriteString(s.lines[j] + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// renderMessage describes m as lines of a script.
+func renderMessage(m *Message) []string {
+	prefix := "#" + m.ChannelID
+	if m.Username != "" {
+		prefix += " webhook " + m.Username
+	}
+
+	var lines []string
+	if m.Content != "" || (m.Embed == nil && len(m.Files) == 0) {
+		lines = append(lines, prefix+": "+fake.EscapeLine(m.Content))
+	}
+	if m.Embed != nil {
+		for _, l := range fake.RenderEmbed(m.Embed) {
+			lines = append(lines, prefix+" embed "+l)
+		}
+	}
+	for _, f := range m.Files {
+		lines = append(lines, fmt.Sprintf("%s file: %s (%d bytes)", prefix, f.Name, len(f.Data)))
+	}
+	return lines
+}
+
+func indent(lines []string) string {
+	if len(lines) == 0 {
+		return "\t(nothing)"
+	}
+	return "\t" + strings.Join(lines, "\n\t")
+}
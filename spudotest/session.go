@@ -0,0 +1,410 @@
+// Package spudotest provides a fake connection to Discord for testing
+// spudo bots and plugins without a network connection.
+package spudotest
+
+import (
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anorb/spudo"
+	"github.com/anorb/spudo/internal/fake"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ID of the fake bot user.
+const BotUserID = "bot"
+
+var errUnknownMessage = errors.New("unknown message")
+
+// Message is a message the bot sent.
+type Message struct {
+	ID              string
+	ChannelID       string
+	Content         string
+	Embed           *discordgo.MessageEmbed
+	Files           []File
+	AllowedMentions *spudo.AllowedMentions
+	Username        string // Username the message was sent as, if it was sent through a webhook
+	Edited          bool
+	Deleted         bool
+	Pinned          bool
+}
+
+// File is a file attached to a message the bot sent.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Reaction is a reaction the bot added to a message.
+type Reaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+}
+
+// VoiceJoin is a voice channel the bot joined.
+type VoiceJoin struct {
+	GuildID   string
+	ChannelID string
+}
+
+// Session is a fake spudo.Backend that records what the bot does
+// instead of sending it to Discord. Events are passed to the bot's
+// handlers with Dispatch or SendMessageCreate.
+type Session struct {
+	mu         sync.Mutex
+	state      *discordgo.State
+	handlers   fake.Handlers
+	messages   []*Message
+	reactions  []Reaction
+	voiceJoins []VoiceJoin
+	typing     []string
+	webhooks   map[string][]*discordgo.Webhook
+	nextID     int
+	transcript []string // Lines describing what the bot did, for RunScript
+	reacted    []string // Lines describing the reactions the bot added, for RunScript
+}
+
+// NewSession returns a Session with an empty state, apart from the
+// bot's own user.
+func NewSession() *Session {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: BotUserID, Username: "spudo", Bot: true}
+	return &Session{
+		state:    state,
+		webhooks: make(map[string][]*discordgo.Webhook),
+	}
+}
+
+func (s *Session) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
+
+// Open dispatches a Ready event, as if the bot had connected to the
+// gateway.
+func (s *Session) Open() error {
+	s.Dispatch(fake.Ready(s.state))
+	return nil
+}
+
+// Close does nothing.
+func (s *Session) Close() error {
+	return nil
+}
+
+// AddHandler registers a handler for events passed to Dispatch. The
+// returned function removes it again.
+func (s *Session) AddHandler(handler interface{}) func() {
+	return s.handlers.Add(handler)
+}
+
+// State returns the state cache, which tests can fill with AddGuild
+// and SetVoiceState.
+func (s *Session) State() *discordgo.State {
+	return s.state
+}
+
+// HeartbeatLatency always returns zero.
+func (s *Session) HeartbeatLatency() time.Duration {
+	return 0
+}
+
+// SendMessage records a message.
+func (s *Session) SendMessage(channelID string, ms *discordgo.MessageSend, am *spudo.AllowedMentions) (*discordgo.Message, error) {
+	m := &Message{ChannelID: channelID, Content: ms.Content, Embed: ms.Embed, AllowedMentions: am}
+	for _, f := range ms.Files {
+		data, err := ioutil.ReadAll(f.Reader)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, File{Name: f.Name, Data: data})
+	}
+	return s.record(m), nil
+}
+
+func (s *Session) record(m *Message) *discordgo.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.ID = s.newID()
+	s.messages = append(s.messages, m)
+	s.transcript = append(s.transcript, renderMessage(m)...)
+	return s.discordMessage(m)
+}
+
+func (s *Session) discordMessage(m *Message) *discordgo.Message {
+	dm := &discordgo.Message{ID: m.ID, ChannelID: m.ChannelID, Content: m.Content, Author: s.state.User}
+	if m.Embed != nil {
+		dm.Embeds = []*discordgo.MessageEmbed{m.Embed}
+	}
+	return dm
+}
+
+func (s *Session) find(channelID, messageID string) *Message {
+	for _, m := range s.messages {
+		if m.ChannelID == channelID && m.ID == messageID && !m.Deleted {
+			return m
+		}
+	}
+	return nil
+}
+
+// EditMessage replaces the content of a message the bot sent.
+func (s *Session) EditMessage(channelID, messageID, content string) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.find(channelID, messageID)
+	if m == nil {
+		return nil, errUnknownMessage
+	}
+	m.Content = content
+	m.Edited = true
+	s.transcript = append(s.transcript, "#"+channelID+" edit "+messageID+": "+fake.EscapeLine(content))
+	return s.discordMessage(m), nil
+}
+
+// DeleteMessage marks a message the bot sent as deleted.
+func (s *Session) DeleteMessage(channelID, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.find(channelID, messageID)
+	if m == nil {
+		return errUnknownMessage
+	}
+	m.Deleted = true
+	s.transcript = append(s.transcript, "#"+channelID+" delete "+messageID)
+	return nil
+}
+
+// PinMessage marks a message the bot sent as pinned.
+func (s *Session) PinMessage(channelID, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.find(channelID, messageID)
+	if m == nil {
+		return errUnknownMessage
+	}
+	m.Pinned = true
+	s.transcript = append(s.transcript, "#"+channelID+" pin "+messageID)
+	return nil
+}
+
+// AddReaction records a reaction.
+func (s *Session) AddReaction(channelID, messageID, emojiID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reactions = append(s.reactions, Reaction{ChannelID: channelID, MessageID: messageID, Emoji: emojiID})
+	s.reacted = append(s.reacted, "#"+channelID+" react "+messageID+": "+emojiID)
+	return nil
+}
+
+// Typing records the typing indicator being shown in channelID.
+func (s *Session) Typing(channelID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.typing = append(s.typing, channelID)
+	return nil
+}
+
+// CreateDM returns a private channel for userID, with the ID "dm-"
+// followed by the user's ID.
+func (s *Session) CreateDM(userID string) (*discordgo.Channel, error) {
+	return fake.DMChannel(userID), nil
+}
+
+// JoinVoice records the bot joining a voice channel. The connection
+// returned can't be used to play audio.
+func (s *Session) JoinVoice(guildID, channelID string, mute, deaf bool) (*discordgo.VoiceConnection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.voiceJoins = append(s.voiceJoins, VoiceJoin{GuildID: guildID, ChannelID: channelID})
+	return &discordgo.VoiceConnection{GuildID: guildID, ChannelID: channelID, UserID: BotUserID}, nil
+}
+
+// ChannelWebhooks returns the webhooks created for channelID.
+func (s *Session) ChannelWebhooks(channelID string) ([]*discordgo.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.Webhook{}, s.webhooks[channelID]...), nil
+}
+
+// CreateWebhook creates a webhook owned by the bot.
+func (s *Session) CreateWebhook(channelID, name string) (*discordgo.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	wh := &discordgo.Webhook{ID: id, ChannelID: channelID, Name: name, Token: "token-" + id, User: s.state.User}
+	s.webhooks[channelID] = append(s.webhooks[channelID], wh)
+	return wh, nil
+}
+
+// DeleteWebhook deletes a webhook.
+func (s *Session) DeleteWebhook(webhookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for channelID, webhooks := range s.webhooks {
+		for i, wh := range webhooks {
+			if wh.ID == webhookID {
+				s.webhooks[channelID] = append(webhooks[:i], webhooks[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return errors.New("unknown webhook")
+}
+
+// ExecuteWebhook records a message sent through a webhook.
+func (s *Session) ExecuteWebhook(webhookID, token string, params *discordgo.WebhookParams, am *spudo.AllowedMentions) (*discordgo.Message, error) {
+	s.mu.Lock()
+	var channelID string
+	for _, webhooks := range s.webhooks {
+		for _, wh := range webhooks {
+			if wh.ID == webhookID && wh.Token == token {
+				channelID = wh.ChannelID
+			}
+		}
+	}
+	s.mu.Unlock()
+	if channelID == "" {
+		return nil, errors.New("unknown webhook")
+	}
+
+	m := &Message{ChannelID: channelID, Content: params.Content, Username: params.Username, AllowedMentions: am}
+	if len(params.Embeds) > 0 {
+		m.Embed = params.Embeds[0]
+	}
+	return s.record(m), nil
+}
+
+// Dispatch passes event, such as a *discordgo.MessageReactionAdd, to
+// every handler registered for its type, as if it had been received
+// from the gateway. It returns once the handlers have returned.
+func (s *Session) Dispatch(event interface{}) {
+	s.handlers.Dispatch(event)
+}
+
+// SendMessageCreate dispatches a message from userID in channelID, as
+// if the user had sent it. It returns once the bot has handled it,
+// including sending any response.
+func (s *Session) SendMessageCreate(channelID, userID, content string) *discordgo.MessageCreate {
+	s.mu.Lock()
+	id := s.newID()
+	s.mu.Unlock()
+
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        id,
+		ChannelID: channelID,
+		Content:   content,
+		Author:    &discordgo.User{ID: userID, Username: userID},
+	}}
+	s.Dispatch(m)
+	return m
+}
+
+// AddGuild adds guild to the state.
+func (s *Session) AddGuild(guild *discordgo.Guild) {
+	s.state.GuildAdd(guild)
+}
+
+// SetVoiceState puts userID in the voice channel channelID of guildID,
+// adding the guild to the state if it isn't already.
+func (s *Session) SetVoiceState(guildID, channelID, userID string) {
+	g, err := s.state.Guild(guildID)
+	if err != nil {
+		g = &discordgo.Guild{ID: guildID}
+		s.state.GuildAdd(g)
+		g, _ = s.state.Guild(guildID)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for _, vs := range g.VoiceStates {
+		if vs.UserID == userID {
+			vs.ChannelID = channelID
+			return
+		}
+	}
+	g.VoiceStates = append(g.VoiceStates, &discordgo.VoiceState{GuildID: guildID, ChannelID: channelID, UserID: userID})
+}
+
+// Messages returns every message the bot has sent, including deleted
+// ones, in the order they were sent.
+func (s *Session) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message{}, s.messages...)
+}
+
+// MessagesIn returns the messages the bot has sent to channelID.
+func (s *Session) MessagesIn(channelID string) []*Message {
+	var messages []*Message
+	for _, m := range s.Messages() {
+		if m.ChannelID == channelID {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// LastMessage returns the last message the bot sent, or nil if it
+// hasn't sent any.
+func (s *Session) LastMessage() *Message {
+	messages := s.Messages()
+	if len(messages) == 0 {
+		return nil
+	}
+	return messages[len(messages)-1]
+}
+
+// Reactions returns every reaction the bot has added.
+func (s *Session) Reactions() []Reaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Reaction{}, s.reactions...)
+}
+
+// VoiceJoins returns every voice channel the bot has joined.
+func (s *Session) VoiceJoins() []VoiceJoin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]VoiceJoin{}, s.voiceJoins...)
+}
+
+// TypingIn returns the IDs of the channels the typing indicator was
+// shown in, once for each time it was sent.
+func (s *Session) TypingIn() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.typing...)
+}
+
+// Reset forgets everything that has been recorded.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	s.reactions = nil
+	s.voiceJoins = nil
+	s.typing = nil
+	s.transcript = nil
+	s.reacted = nil
+}
+
+// takeTranscript returns what the bot has done since it was last
+// called. Reactions are listed after everything else and sorted, as
+// the handlers that add them run concurrently with each other and with
+// commands.
+func (s *Session) takeTranscript() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Strings(s.reacted)
+	t := append(s.transcript, s.reacted...)
+	s.transcript = nil
+	s.reacted = nil
+	return t
+}
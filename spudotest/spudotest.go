@@ -0,0 +1,32 @@
+package spudotest
+
+import (
+	"testing"
+
+	"github.com/anorb/spudo"
+)
+
+// New returns a bot that uses a fake Session instead of connecting to
+// Discord. It starts from spudo's default config with only errors
+// logged and values stored in memory, and configure can change it
+// further. Plugins are added to the bot as usual, then Connect starts
+// it.
+func New(tb testing.TB, configure func(config *spudo.Config)) (*spudo.Spudo, *Session) {
+	tb.Helper()
+
+	config := spudo.DefaultConfig()
+	config.Token = "test"
+	config.LogLevel = "error"
+	config.StorePath = ""
+	if configure != nil {
+		configure(&config)
+	}
+
+	bot, err := spudo.New(config)
+	if err != nil {
+		tb.Fatalf("Error creating bot - %s", err)
+	}
+	session := NewSession()
+	bot.SetBackend(session)
+	return bot, session
+}
@@ -0,0 +1,263 @@
+package spudotest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anorb/spudo"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestCommandResponse(t *testing.T) {
+	bot, session := New(t, func(config *spudo.Config) {
+		config.TypingDelayMS = 0
+	})
+	bot.AddCommand("hello", "says hello", func(author string, args []string) interface{} {
+		return "hello " + strings.Join(args, " ")
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.SendMessageCreate("general", "alice", "!hello world")
+
+	messages := session.MessagesIn("general")
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if want := "<@alice> hello world"; messages[0].Content != want {
+		t.Errorf("expected %q, got %q", want, messages[0].Content)
+	}
+	if len(session.TypingIn()) != 0 {
+		t.Errorf("expected no typing indicator, got %v", session.TypingIn())
+	}
+}
+
+func TestBotMessagesIgnored(t *testing.T) {
+	bot, session := New(t, nil)
+	bot.AddCommand("hello", "says hello", func(author string, args []string) interface{} {
+		return "hello"
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.Dispatch(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "1",
+		ChannelID: "general",
+		Content:   "!hello",
+		Author:    &discordgo.User{ID: "other-bot", Bot: true},
+	}})
+
+	if messages := session.Messages(); len(messages) != 0 {
+		t.Errorf("expected no messages, got %d", len(messages))
+	}
+}
+
+func TestMessageReaction(t *testing.T) {
+	bot, session := New(t, nil)
+	bot.AddMessageReaction("potato", []string{"potato"}, []string{"🥔"})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	m := session.SendMessageCreate("general", "alice", "I like potato salad")
+
+	want := []Reaction{{ChannelID: "general", MessageID: m.ID, Emoji: "🥔"}}
+	got := session.Reactions()
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("expected reactions %v, got %v", want, got)
+	}
+}
+
+func TestDeferredEdit(t *testing.T) {
+	bot, session := New(t, func(config *spudo.Config) {
+		config.TypingDelayMS = 0
+	})
+	bot.AddCommand("deploy", "deploys", func(author string, args []string) interface{} {
+		return spudo.Defer("Deploying...", func(r *spudo.Reply) {
+			r.Edit("Deployed!")
+		})
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.SendMessageCreate("general", "alice", "!deploy")
+
+	messages := session.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	if !messages[0].Edited || messages[0].Content != "Deployed!" {
+		t.Errorf("expected message edited to %q, got %q (edited %v)", "Deployed!", messages[0].Content, messages[0].Edited)
+	}
+}
+
+func TestEventPlugin(t *testing.T) {
+	bot, session := New(t, nil)
+	bot.AddReactionAddPlugin("star", func(ctx *spudo.EventContext, r *discordgo.MessageReaction) {
+		if r.Emoji.Name == "⭐" {
+			ctx.Reply("starred")
+		}
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.Dispatch(&discordgo.MessageReactionAdd{MessageReaction: &discordgo.MessageReaction{
+		UserID:    "alice",
+		MessageID: "1",
+		ChannelID: "general",
+		Emoji:     discordgo.Emoji{Name: "⭐"},
+	}})
+
+	m := session.LastMessage()
+	if m == nil || m.ChannelID != "general" || m.Content != "starred" {
+		t.Errorf("expected reply in general, got %+v", m)
+	}
+}
+
+func TestStartupPlugin(t *testing.T) {
+	bot, session := New(t, nil)
+	session.AddGuild(&discordgo.Guild{ID: "guild"})
+	started := 0
+	bot.AddStartupPlugin("count", func() {
+		started++
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.Open()
+
+	if started != 1 {
+		t.Errorf("expected startup plugin to run once, ran %d times", started)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	bot, session := New(t, func(config *spudo.Config) {
+		config.TypingDelayMS = 0
+		config.CooldownTimer = 0
+	})
+	bot.AddStartupPlugin("hello", func() {
+		bot.SendMessage("general", "I'm back!")
+	})
+	bot.AddShutdownPlugin("goodbye", func() {
+		bot.SendMessage("general", "Shutting down!")
+	})
+	bot.AddCommand("ping", "responds with pong", func(author string, args []string) interface{} {
+		return "Pong!"
+	})
+	bot.AddCommand("embed", "responds with an embed", func(author string, args []string) interface{} {
+		return spudo.NewEmbed().SetTitle("Title").SetDescription("Line one\nLine two").AddField("Field", "Value", false)
+	})
+	bot.AddCommand("deploy", "deploys", func(author string, args []string) interface{} {
+		return spudo.Defer("Deploying...", func(r *spudo.Reply) {
+			r.Edit("Deployed!")
+		})
+	})
+	bot.AddMessageReaction("ok", []string{"ok"}, []string{"👌"})
+	bot.AddUserReaction("alice", []string{"alice"}, []string{"👋"})
+
+	RunScript(t, bot, session, "testdata/conversation.txt")
+}
+
+func TestParseScript(t *testing.T) {
+	for _, script := range []string{
+		"> general alice: !ping\n",
+		"> #general alice !ping\n",
+		"> #general: !ping\n",
+		"!ping\n",
+	} {
+		if _, err := parseScript(script); err == nil {
+			t.Errorf("expected error parsing %q", script)
+		}
+	}
+
+	script := "# comment\n> #general alice: !ping\n\n> #general bob: hi\\nthere\n< #general: old\n# trailing\n"
+	steps, err := parseScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 || steps[2].user != "bob" || steps[2].content != "hi\nthere" {
+		t.Fatalf("unexpected steps %+v", steps)
+	}
+
+	got := formatScript(steps, [][]string{{"#general: start"}, {"#general: one"}, {"#general: new"}})
+	want := "# comment\n< #general: start\n> #general alice: !ping\n< #general: one\n\n> #general bob: hi\\nthere\n< #general: new\n# trailing\n"
+	if got != want {
+		t.Errorf("expected script:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestPrivateMentionResponse(t *testing.T) {
+	bot, session := New(t, func(config *spudo.Config) {
+		config.TypingDelayMS = 0
+	})
+	bot.AddCommand("secret", "whispers", func(author string, args []string) interface{} {
+		return spudo.WithMentions("psst <@bob>", spudo.NoMentions())
+	}, spudo.Private())
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.SendMessageCreate("general", "alice", "!secret")
+
+	if messages := session.MessagesIn("general"); len(messages) != 0 {
+		t.Errorf("expected nothing sent in the channel, got %q", messages[0].Content)
+	}
+	messages := session.MessagesIn("dm-alice")
+	if len(messages) != 1 || messages[0].Content != "psst <@bob>" {
+		t.Fatalf("expected private response, got %+v", messages)
+	}
+	if am := messages[0].AllowedMentions; am == nil || len(am.Parse) != 0 || len(am.Users) != 0 {
+		t.Errorf("expected no mentions allowed, got %+v", am)
+	}
+}
+
+func TestTypingIndicator(t *testing.T) {
+	bot, session := New(t, func(config *spudo.Config) {
+		config.TypingDelayMS = 20
+		config.CooldownTimer = 0
+	})
+	bot.AddCommand("fast", "answers straight away", func(author string, args []string) interface{} {
+		return "done"
+	})
+	bot.AddCommand("slow", "waits for the typing indicator", func(author string, args []string) interface{} {
+		deadline := time.Now().Add(time.Second)
+		for len(session.TypingIn()) == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return "done"
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	session.SendMessageCreate("general", "alice", "!fast")
+	time.Sleep(50 * time.Millisecond)
+	if typing := session.TypingIn(); len(typing) != 0 {
+		t.Errorf("expected no typing indicator for a command that finished within the delay, got %v", typing)
+	}
+
+	session.SendMessageCreate("general", "alice", "!slow")
+	typing := session.TypingIn()
+	if len(typing) != 1 || typing[0] != "general" {
+		t.Fatalf("expected the typing indicator once the delay passed, got %v", typing)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after := session.TypingIn(); len(after) != len(typing) {
+		t.Errorf("expected the typing indicator to stop once the response was sent, got %v", after)
+	}
+}
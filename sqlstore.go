@@ -0,0 +1,322 @@
+package spudo
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQL dialects supported by SQLStore.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// migrationPlugin is the name the store's own migrations are
+// registered under.
+const migrationPlugin = "spudo"
+
+// driverDialects maps the names database/sql drivers are registered
+// under to the dialect they use.
+var driverDialects = map[string]string{
+	"sqlite":   DialectSQLite,
+	"sqlite3":  DialectSQLite,
+	"postgres": DialectPostgres,
+	"pgx":      DialectPostgres,
+}
+
+// migration is a change to the database schema, run once in order of
+// Version with the other migrations of the same plugin.
+type migration struct {
+	Plugin     string   // Name of the plugin the migration belongs to
+	Version    int      // Version of the plugin's schema the migration brings the database to
+	Statements []string // SQL statements run in a transaction to migrate
+}
+
+// AddMigration registers SQL statements that bring the plugin's tables
+// to version. When a SQL store is used, migrations that haven't been
+// run yet are run in order of version when the bot starts. Each is run
+// in a transaction and only once.
+//
+// Migrations have to be added before Start or Connect, as they are
+// only run then.
+func (sp *Spudo) AddMigration(plugin string, version int, statements ...string) {
+	if sp.migrated {
+		sp.logger.Warn("Failed to add migration - migrations have already run", "plugin", plugin, "version", version)
+		return
+	}
+	for _, m := range sp.migrations {
+		if m.Plugin == plugin && m.Version == version {
+			sp.logger.Warn("Failed to add migration - version already exists", "plugin", plugin, "version", version)
+			return
+		}
+	}
+	sp.migrations = append(sp.migrations, &migration{
+		Plugin:     plugin,
+		Version:    version,
+		Statements: statements,
+	})
+	sp.logger.Info("Migration added", "plugin", plugin, "version", version)
+}
+
+// SQLStore is a Store that keeps values in a SQL database, for bots
+// that need more than a single file. It works with any database/sql
+// driver for SQLite or Postgres, which has to be imported by the bot.
+// Importing the sqlstore package registers a pure Go "sqlite" driver.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string
+	now     func() time.Time
+}
+
+// NewSQLStore returns a Store using db, which uses dialect,
+// DialectSQLite or DialectPostgres. Its table is created when the bot
+// starts, along with the other migrations.
+func NewSQLStore(db *sql.DB, dialect string) (*SQLStore, error) {
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return nil, errors.New("unsupported SQL dialect " + dialect)
+	}
+	return &SQLStore{db: db, dialect: dialect, now: time.Now}, nil
+}
+
+// DB returns the database the store uses, so plugins can query the
+// tables their migrations create.
+func (ss *SQLStore) DB() *sql.DB {
+	return ss.db
+}
+
+// DB returns the database used by the store when it is a SQLStore, such
+// as one opened with StoreDriver, or nil otherwise. It is only set once
+// the bot has been started.
+func (sp *Spudo) DB() *sql.DB {
+	if ss, ok := sp.storage.(*SQLStore); ok {
+		return ss.db
+	}
+	return nil
+}
+
+// storeMigrations are the migrations that create the SQLStore's table.
+func (ss *SQLStore) storeMigrations() []*migration {
+	blob := "BLOB"
+	if ss.dialect == DialectPostgres {
+		blob = "BYTEA"
+	}
+	return []*migration{{
+		Plugin:  migrationPlugin,
+		Version: 1,
+		Statements: []string{`CREATE TABLE spudo_store (
+	plugin TEXT NOT NULL,
+	guild_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value ` + blob + ` NOT NULL,
+	expires BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (plugin, guild_id, user_id, name)
+)`},
+	}}
+}
+
+// rebind replaces the ? placeholders in query with the ones the
+// dialect uses.
+func (ss *SQLStore) rebind(query string) string {
+	if ss.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// migrate runs the store's own migrations and then migrations, skipping
+// any that have already been run.
+func (ss *SQLStore) migrate(migrations []*migration) error {
+	if _, err := ss.db.Exec(`CREATE TABLE IF NOT EXISTS spudo_migrations (
+	plugin TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	PRIMARY KEY (plugin, version)
+)`); err != nil {
+		return err
+	}
+
+	applied := make(map[string]map[int]bool)
+	rows, err := ss.db.Query("SELECT plugin, version FROM spudo_migrations")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var plugin string
+		var version int
+		if err := rows.Scan(&plugin, &version); err != nil {
+			return err
+		}
+		if applied[plugin] == nil {
+			applied[plugin] = make(map[int]bool)
+		}
+		applied[plugin][version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range pendingMigrations(append(ss.storeMigrations(), migrations...), applied) {
+		if err := ss.runMigration(m); err != nil {
+			return errors.New("Failed to run migration " + strconv.Itoa(m.Version) + " of " + m.Plugin + " - " + err.Error())
+		}
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations that aren't in applied,
+// ordered by plugin and then version.
+func pendingMigrations(migrations []*migration, applied map[string]map[int]bool) []*migration {
+	var pending []*migration
+	for _, m := range migrations {
+		if !applied[m.Plugin][m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		// The store's own table comes first as plugins may refer to it
+		if (pending[i].Plugin == migrationPlugin) != (pending[j].Plugin == migrationPlugin) {
+			return pending[i].Plugin == migrationPlugin
+		}
+		if pending[i].Plugin != pending[j].Plugin {
+			return pending[i].Plugin < pending[j].Plugin
+		}
+		return pending[i].Version < pending[j].Version
+	})
+	return pending
+}
+
+func (ss *SQLStore) runMigration(m *migration) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range m.Statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(ss.rebind("INSERT INTO spudo_migrations (plugin, version) VALUES (?, ?)"), m.Plugin, m.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ss *SQLStore) Get(scope Scope, key string) ([]byte, error) {
+	var value []byte
+	err := ss.db.QueryRow(
+		ss.rebind("SELECT value FROM spudo_store WHERE plugin = ? AND guild_id = ? AND user_id = ? AND name = ? AND (expires = 0 OR expires > ?)"),
+		scope.Plugin, scope.GuildID, scope.UserID, key, ss.now().UnixNano(),
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Set stores value, removing any values in scope that have expired.
+func (ss *SQLStore) Set(scope Scope, key string, value []byte, ttl time.Duration) error {
+	now := ss.now()
+	var expires int64
+	if ttl > 0 {
+		expires = now.Add(ttl).UnixNano()
+	}
+	if _, err := ss.db.Exec(
+		ss.rebind("INSERT INTO spudo_store (plugin, guild_id, user_id, name, value, expires) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (plugin, guild_id, user_id, name) DO UPDATE SET value = excluded.value, expires = excluded.expires"),
+		scope.Plugin, scope.GuildID, scope.UserID, key, value, expires,
+	); err != nil {
+		return err
+	}
+	_, err := ss.db.Exec(
+		ss.rebind("DELETE FROM spudo_store WHERE plugin = ? AND guild_id = ? AND user_id = ? AND expires <> 0 AND expires <= ?"),
+		scope.Plugin, scope.GuildID, scope.UserID, now.UnixNano(),
+	)
+	return err
+}
+
+func (ss *SQLStore) Delete(scope Scope, key string) error {
+	_, err := ss.db.Exec(
+		ss.rebind("DELETE FROM spudo_store WHERE plugin = ? AND guild_id = ? AND user_id = ? AND name = ?"),
+		scope.Plugin, scope.GuildID, scope.UserID, key,
+	)
+	return err
+}
+
+func (ss *SQLStore) List(scope Scope) ([]string, error) {
+	rows, err := ss.db.Query(
+		ss.rebind("SELECT name FROM spudo_store WHERE plugin = ? AND guild_id = ? AND user_id = ? AND (expires = 0 OR expires > ?) ORDER BY name"),
+		scope.Plugin, scope.GuildID, scope.UserID, ss.now().UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// openSQLStore opens the database configured with StoreDriver and
+// StoreDSN.
+func (sp *Spudo) openSQLStore() error {
+	dialect, ok := driverDialects[sp.Config.StoreDriver]
+	if !ok {
+		return errors.New("unsupported store driver " + sp.Config.StoreDriver)
+	}
+	if !driverRegistered(sp.Config.StoreDriver) {
+		hint := "import its driver"
+		if sp.Config.StoreDriver == "sqlite" {
+			hint = "import github.com/anorb/spudo/sqlstore"
+		}
+		return errors.New("store driver " + sp.Config.StoreDriver + " isn't registered - " + hint)
+	}
+	db, err := sql.Open(sp.Config.StoreDriver, sp.Config.StoreDSN)
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+	ss, err := NewSQLStore(db, dialect)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	sp.storeDB = db
+	sp.storage = ss
+	return nil
+}
+
+func driverRegistered(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+// Package sqlstore provides SQLite storage for spudo bots. Importing
+// it registers the pure Go "sqlite" database/sql driver, so that
+// StoreDriver="sqlite" works without cgo, and Open returns a store on a
+// SQLite database for bots that set it with SetStore.
+//
+// The driver is kept out of the spudo package so bots that don't use
+// SQLite don't have to build it:
+//
+//	import _ "github.com/anorb/spudo/sqlstore"
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/anorb/spudo"
+
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// Open opens the SQLite database at dsn and returns a store using it.
+// The database is closed by closing store.DB() once the bot has shut
+// down.
+func Open(dsn string) (*spudo.SQLStore, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.New("Failed to open SQLite database - " + err.Error())
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.New("Failed to open SQLite database - " + err.Error())
+	}
+	ss, err := spudo.NewSQLStore(db, spudo.DialectSQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ss, nil
+}
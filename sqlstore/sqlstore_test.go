@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anorb/spudo"
+	"github.com/anorb/spudo/spudotest"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-sqlstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ss, err := Open(filepath.Join(dir, "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.DB().Close()
+
+	bot, _ := spudotest.New(t, nil)
+	bot.SetStore(ss)
+	if err := bot.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Shutdown()
+
+	store := bot.PluginStore("points")
+	if err := store.Set("score", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := store.Get("score"); err != nil || string(v) != "1" {
+		t.Errorf("Expected 1 - got %q (%v)", v, err)
+	}
+}
+
+func TestStoreDriver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-sqlstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bot, _ := spudotest.New(t, func(config *spudo.Config) {
+		config.StoreDriver = "sqlite"
+		config.StoreDSN = filepath.Join(dir, "bot.db")
+	})
+	if err := bot.Connect(); err != nil {
+		t.Fatalf("Expected importing sqlstore to register the sqlite driver - got %v", err)
+	}
+	defer bot.Shutdown()
+
+	if bot.DB() == nil {
+		t.Error("Expected the bot to use a SQL store")
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if _, err := Open(filepath.Join("missing", "dir", "bot.db")); err == nil {
+		t.Error("Expected opening a database in a missing directory to fail")
+	}
+}
@@ -0,0 +1,192 @@
+package spudo
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	sp := newSpudo()
+	sp.AddMigration("quotes", 2, "ALTER TABLE quotes ADD COLUMN author TEXT")
+	sp.AddMigration("quotes", 1, "CREATE TABLE quotes (id INTEGER PRIMARY KEY, text TEXT)")
+	sp.AddMigration("points", 1, "CREATE TABLE points (user_id TEXT PRIMARY KEY, points INTEGER)")
+	sp.AddMigration("quotes", 1, "CREATE TABLE duplicate (id INTEGER)")
+
+	if len(sp.migrations) != 3 {
+		t.Fatalf("expected duplicate migration to be ignored, got %d migrations", len(sp.migrations))
+	}
+
+	ss, err := NewSQLStore(nil, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := append(ss.storeMigrations(), sp.migrations...)
+	applied := map[string]map[int]bool{"quotes": {1: true}}
+
+	var got []string
+	for _, m := range pendingMigrations(all, applied) {
+		got = append(got, m.Plugin+" "+strconv.Itoa(m.Version))
+	}
+	want := []string{"spudo 1", "points 1", "quotes 2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected migrations %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected migrations %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestSQLStoreRebind(t *testing.T) {
+	query := "SELECT value FROM spudo_store WHERE plugin = ? AND name = ?"
+	sqlite, _ := NewSQLStore(nil, DialectSQLite)
+	if got := sqlite.rebind(query); got != query {
+		t.Errorf("expected SQLite query to be unchanged, got %q", got)
+	}
+	postgres, _ := NewSQLStore(nil, DialectPostgres)
+	if got, want := postgres.rebind(query), "SELECT value FROM spudo_store WHERE plugin = $1 AND name = $2"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if _, err := NewSQLStore(nil, "mysql"); err == nil {
+		t.Error("expected unsupported dialect to be rejected")
+	}
+}
+
+// openTestSQLStore returns a SQLStore on a new in-memory SQLite
+// database.
+func openTestSQLStore(t *testing.T) (*SQLStore, *sql.DB) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+	ss, err := NewSQLStore(db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss, db
+}
+
+func TestSQLStoreMigrate(t *testing.T) {
+	ss, db := openTestSQLStore(t)
+	defer db.Close()
+
+	migrations := []*migration{
+		{Plugin: "quotes", Version: 1, Statements: []string{"CREATE TABLE quotes (id INTEGER PRIMARY KEY, text TEXT NOT NULL)"}},
+		{Plugin: "quotes", Version: 2, Statements: []string{"ALTER TABLE quotes ADD COLUMN author TEXT"}},
+	}
+	if err := ss.migrate(migrations); err != nil {
+		t.Fatal(err)
+	}
+	// The statements would fail if they were run again
+	if err := ss.migrate(migrations); err != nil {
+		t.Fatalf("expected second migration to do nothing, got %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM spudo_migrations").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 migrations recorded, got %d", count)
+	}
+	if _, err := db.Exec("INSERT INTO quotes (text, author) VALUES ('hi', 'alice')"); err != nil {
+		t.Errorf("expected migrated quotes table, got %v", err)
+	}
+
+	failing := []*migration{{Plugin: "broken", Version: 1, Statements: []string{"CREATE TABLE broken (id INTEGER)", "NOT SQL"}}}
+	if err := ss.migrate(failing); err == nil {
+		t.Fatal("expected invalid migration to fail")
+	}
+	if _, err := db.Exec("SELECT * FROM broken"); err == nil {
+		t.Error("expected failed migration to be rolled back")
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	ss, db := openTestSQLStore(t)
+	defer db.Close()
+	if err := ss.migrate(nil); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ss.now = func() time.Time { return now }
+	user := Scope{Plugin: "points", GuildID: "guild", UserID: "alice"}
+
+	if _, err := ss.Get(user, "score"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := ss.Set(user, "score", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Set(user, "score", []byte("2"), 0); err != nil {
+		t.Fatalf("expected existing value to be replaced, got %v", err)
+	}
+	if v, err := ss.Get(user, "score"); err != nil || string(v) != "2" {
+		t.Errorf("expected 2, got %q (%v)", v, err)
+	}
+	if _, err := ss.Get(Scope{Plugin: "points", GuildID: "guild"}, "score"); err != ErrNotFound {
+		t.Errorf("expected value to be scoped to the user, got %v", err)
+	}
+
+	if err := ss.Set(user, "boost", []byte("x"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := ss.List(user); !reflect.DeepEqual(keys, []string{"boost", "score"}) {
+		t.Errorf("expected keys [boost score], got %v", keys)
+	}
+	now = now.Add(time.Minute)
+	if _, err := ss.Get(user, "boost"); err != ErrNotFound {
+		t.Errorf("expected expired value to be gone, got %v", err)
+	}
+	if keys, _ := ss.List(user); !reflect.DeepEqual(keys, []string{"score"}) {
+		t.Errorf("expected keys [score], got %v", keys)
+	}
+
+	if err := ss.Delete(user, "score"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := ss.List(user); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestSpudoDB(t *testing.T) {
+	sp := newSpudo()
+	if sp.DB() != nil {
+		t.Error("expected no database without a SQLStore")
+	}
+
+	ss, db := openTestSQLStore(t)
+	defer db.Close()
+	sp.SetStore(ss)
+	sp.AddMigration("quotes", 1, "CREATE TABLE quotes (id INTEGER PRIMARY KEY, text TEXT NOT NULL)")
+	if err := sp.openStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sp.DB().Exec("INSERT INTO quotes (text) VALUES ('hi')"); err != nil {
+		t.Errorf("expected plugin table to be usable through DB, got %v", err)
+	}
+	sp.AddMigration("quotes", 2, "ALTER TABLE quotes ADD COLUMN author TEXT")
+	if len(sp.migrations) != 1 {
+		t.Errorf("expected migration added after starting to be ignored, got %d migrations", len(sp.migrations))
+	}
+}
+
+func TestOpenSQLStoreUnregisteredDriver(t *testing.T) {
+	sp := newSpudo()
+	sp.Config.StoreDriver = "pgx"
+	if err := sp.openSQLStore(); err == nil || !strings.Contains(err.Error(), "isn't registered") {
+		t.Errorf("expected an error for a driver that isn't imported, got %v", err)
+	}
+}
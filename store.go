@@ -0,0 +1,344 @@
+package spudo
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Store when a key has no value, or its
+// value has expired.
+var ErrNotFound = errors.New("key not found")
+
+var errNoStore = errors.New("store isn't open until the bot is started")
+
+// Scope identifies who a stored value belongs to. Values in one scope
+// can't be seen from another.
+type Scope struct {
+	Plugin  string // Name of the plugin the value belongs to
+	GuildID string // ID of the guild the value belongs to, empty if it isn't specific to one
+	UserID  string // ID of the user the value belongs to, empty if it isn't specific to one
+}
+
+// Store persists values for plugins so they survive the bot
+// restarting. The default stores everything in the file at StorePath,
+// and SetStore can replace it.
+type Store interface {
+	// Get returns the value of key in scope, or ErrNotFound.
+	Get(scope Scope, key string) ([]byte, error)
+	// Set stores value as key in scope. A ttl above zero makes the
+	// value expire after that long.
+	Set(scope Scope, key string, value []byte, ttl time.Duration) error
+	// Delete removes key from scope. Deleting a key that doesn't exist
+	// isn't an error.
+	Delete(scope Scope, key string) error
+	// List returns the keys in scope that have a value, in order.
+	List(scope Scope) ([]string, error)
+}
+
+// SetStore replaces the Store plugins use. It must be called before
+// Start or Connect.
+func (sp *Spudo) SetStore(s Store) {
+	sp.storage = s
+}
+
+// openStore opens the database set by StoreDriver, or otherwise the
+// file store at StorePath, unless another Store has been set. The
+// migrations are then run if it is a SQLStore.
+func (sp *Spudo) openStore() error {
+	if sp.storage == nil {
+		if sp.Config.StoreDriver != "" {
+			if err := sp.openSQLStore(); err != nil {
+				return err
+			}
+		} else {
+			fs, err := newFileStore(sp.Config.StorePath)
+			if err != nil {
+				return err
+			}
+			sp.storage = fs
+		}
+	}
+
+	sp.migrated = true
+	if ss, ok := sp.storage.(*SQLStore); ok {
+		return ss.migrate(sp.migrations)
+	}
+	if len(sp.migrations) > 0 {
+		sp.logger.Warn("Migrations ignored - store isn't a SQLStore", "migrations", len(sp.migrations))
+	}
+	return nil
+}
+
+// PluginStore is a plugin's view of the Store, limited to its own
+// values. Guild and User narrow it further.
+type PluginStore struct {
+	sp    *Spudo
+	scope Scope
+}
+
+// PluginStore returns the values stored by the plugin name. It can be
+// used from commands, timed messages and REST routes alike, once the
+// bot has been started. Commands added with AddCommand only know the
+// author, so they can store values for users or the whole bot, while
+// those added with AddContextCommand can use the guild they were used
+// in.
+func (sp *Spudo) PluginStore(name string) *PluginStore {
+	return &PluginStore{sp: sp, scope: Scope{Plugin: name}}
+}
+
+// Guild returns the values ps stores for guildID.
+func (ps *PluginStore) Guild(guildID string) *PluginStore {
+	scope := ps.scope
+	scope.GuildID = guildID
+	return &PluginStore{sp: ps.sp, scope: scope}
+}
+
+// User returns the values ps stores for userID.
+func (ps *PluginStore) User(userID string) *PluginStore {
+	scope := ps.scope
+	scope.UserID = userID
+	return &PluginStore{sp: ps.sp, scope: scope}
+}
+
+// Scope returns the scope values are stored in.
+func (ps *PluginStore) Scope() Scope {
+	return ps.scope
+}
+
+func (ps *PluginStore) store() (Store, error) {
+	if ps.sp.storage == nil {
+		return nil, errNoStore
+	}
+	return ps.sp.storage, nil
+}
+
+// Get returns the value of key, or ErrNotFound.
+func (ps *PluginStore) Get(key string) ([]byte, error) {
+	s, err := ps.store()
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ps.scope, key)
+}
+
+// Set stores value as key. A ttl above zero makes the value expire
+// after that long.
+func (ps *PluginStore) Set(key string, value []byte, ttl time.Duration) error {
+	s, err := ps.store()
+	if err != nil {
+		return err
+	}
+	if err := s.Set(ps.scope, key, value, ttl); err != nil {
+		ps.sp.logger.Error("Failed to store value", "plugin", ps.scope.Plugin, "key", key, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Delete removes key.
+func (ps *PluginStore) Delete(key string) error {
+	s, err := ps.store()
+	if err != nil {
+		return err
+	}
+	if err := s.Delete(ps.scope, key); err != nil {
+		ps.sp.logger.Error("Failed to delete value", "plugin", ps.scope.Plugin, "key", key, "error", err)
+		return err
+	}
+	return nil
+}
+
+// List returns the keys that have a value, in order.
+func (ps *PluginStore) List() ([]string, error) {
+	s, err := ps.store()
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ps.scope)
+}
+
+// GetJSON decodes the value of key into v.
+func (ps *PluginStore) GetJSON(key string, v interface{}) error {
+	value, err := ps.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, v)
+}
+
+// SetJSON stores v encoded as JSON as key.
+func (ps *PluginStore) SetJSON(key string, v interface{}, ttl time.Duration) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ps.Set(key, value, ttl)
+}
+
+// storeEntry is a value in a fileStore.
+type storeEntry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"` // Zero if the value doesn't expire
+}
+
+// storeData holds values by plugin, guild, user and then key.
+type storeData map[string]map[string]map[string]map[string]storeEntry
+
+// fileStore is the default Store. Everything is kept in memory and the
+// whole store is written to path after every change, to a temporary
+// file that then replaces the old one so a crash can't leave it half
+// written. With no path the values are only kept in memory.
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+	data storeData
+	now  func() time.Time
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	fs := &fileStore{path: path, data: make(storeData), now: time.Now}
+	if path == "" {
+		return fs, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, errors.New("Failed to read store - " + err.Error())
+	}
+	if err := json.Unmarshal(b, &fs.data); err != nil {
+		return nil, errors.New("Failed to read store - " + err.Error())
+	}
+	return fs, nil
+}
+
+// entries returns the values in scope, creating the maps for it if
+// create is true.
+func (fs *fileStore) entries(scope Scope, create bool) map[string]storeEntry {
+	guilds, ok := fs.data[scope.Plugin]
+	if !ok {
+		if !create {
+			return nil
+		}
+		guilds = make(map[string]map[string]map[string]storeEntry)
+		fs.data[scope.Plugin] = guilds
+	}
+	users, ok := guilds[scope.GuildID]
+	if !ok {
+		if !create {
+			return nil
+		}
+		users = make(map[string]map[string]storeEntry)
+		guilds[scope.GuildID] = users
+	}
+	entries, ok := users[scope.UserID]
+	if !ok && create {
+		entries = make(map[string]storeEntry)
+		users[scope.UserID] = entries
+	}
+	return entries
+}
+
+func (fs *fileStore) expired(e storeEntry) bool {
+	return !e.Expires.IsZero() && !fs.now().Before(e.Expires)
+}
+
+func (fs *fileStore) Get(scope Scope, key string) ([]byte, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e, ok := fs.entries(scope, false)[key]
+	if !ok || fs.expired(e) {
+		return nil, ErrNotFound
+	}
+	// Copied so callers can't change the stored value
+	return append([]byte{}, e.Value...), nil
+}
+
+func (fs *fileStore) Set(scope Scope, key string, value []byte, ttl time.Duration) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e := storeEntry{Value: append([]byte{}, value...)}
+	if ttl > 0 {
+		e.Expires = fs.now().Add(ttl)
+	}
+	fs.entries(scope, true)[key] = e
+	return fs.save()
+}
+
+func (fs *fileStore) Delete(scope Scope, key string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	entries := fs.entries(scope, false)
+	if _, ok := entries[key]; !ok {
+		return nil
+	}
+	delete(entries, key)
+	return fs.save()
+}
+
+func (fs *fileStore) List(scope Scope) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	keys := []string{}
+	for key, e := range fs.entries(scope, false) {
+		if !fs.expired(e) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// save removes expired values, then writes the store to a temporary
+// file and renames it over path.
+func (fs *fileStore) save() error {
+	for _, guilds := range fs.data {
+		for _, users := range guilds {
+			for _, entries := range users {
+				for key, e := range entries {
+					if fs.expired(e) {
+						delete(entries, key)
+					}
+				}
+			}
+		}
+	}
+	if fs.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(fs.data)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fs.path), filepath.Base(fs.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), fs.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package spudo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spudo-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.json")
+
+	fs, err := newFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := Scope{Plugin: "points", GuildID: "guild", UserID: "alice"}
+	guild := Scope{Plugin: "points", GuildID: "guild"}
+
+	if _, err := fs.Get(user, "score"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := fs.Set(user, "score", []byte("10"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set(user, "rank", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set(guild, "total", []byte("10"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := fs.Get(user, "score"); err != nil || string(v) != "10" {
+		t.Errorf("expected 10, got %q (%v)", v, err)
+	}
+	if _, err := fs.Get(guild, "score"); err != ErrNotFound {
+		t.Errorf("expected value to be scoped to the user, got %v", err)
+	}
+	if keys, _ := fs.List(user); !reflect.DeepEqual(keys, []string{"rank", "score"}) {
+		t.Errorf("expected keys [rank score], got %v", keys)
+	}
+
+	if err := fs.Delete(user, "rank"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Delete(user, "missing"); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+
+	reopened, err := newFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := reopened.Get(user, "score"); err != nil || string(v) != "10" {
+		t.Errorf("expected 10 after reopening, got %q (%v)", v, err)
+	}
+	if _, err := reopened.Get(user, "rank"); err != ErrNotFound {
+		t.Errorf("expected deleted key to stay deleted, got %v", err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected only the store file to be left, got %d files", len(files))
+	}
+}
+
+func TestFileStoreTTL(t *testing.T) {
+	fs, err := newFileStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	fs.now = func() time.Time { return now }
+	scope := Scope{Plugin: "reminders"}
+
+	fs.Set(scope, "soon", []byte("x"), time.Minute)
+	fs.Set(scope, "forever", []byte("y"), 0)
+	if _, err := fs.Get(scope, "soon"); err != nil {
+		t.Errorf("expected value before it expires, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := fs.Get(scope, "soon"); err != ErrNotFound {
+		t.Errorf("expected expired value to be gone, got %v", err)
+	}
+	if keys, _ := fs.List(scope); !reflect.DeepEqual(keys, []string{"forever"}) {
+		t.Errorf("expected keys [forever], got %v", keys)
+	}
+}
+
+func TestPluginStore(t *testing.T) {
+	sp := newSpudo()
+	ps := sp.PluginStore("points")
+	if err := ps.Set("score", []byte("1"), 0); err != errNoStore {
+		t.Errorf("expected errNoStore before the store is open, got %v", err)
+	}
+
+	if err := sp.openStore(); err != nil {
+		t.Fatal(err)
+	}
+	type points struct {
+		Score int
+	}
+	if err := ps.Guild("guild").User("alice").SetJSON("points", points{Score: 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+	var p points
+	if err := sp.PluginStore("points").Guild("guild").User("alice").GetJSON("points", &p); err != nil || p.Score != 3 {
+		t.Errorf("expected score 3, got %d (%v)", p.Score, err)
+	}
+	if _, err := sp.PluginStore("other").Guild("guild").User("alice").Get("points"); err != ErrNotFound {
+		t.Errorf("expected value to be scoped to the plugin, got %v", err)
+	}
+}
+
+func TestFileStoreCopiesValues(t *testing.T) {
+	fs, err := newFileStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := Scope{Plugin: "points"}
+
+	value := []byte("10")
+	fs.Set(scope, "score", value, 0)
+	value[0] = '2'
+	got, _ := fs.Get(scope, "score")
+	got[1] = '5'
+	if again, _ := fs.Get(scope, "score"); string(again) != "10" {
+		t.Errorf("Expected the stored value not to change - got %q", again)
+	}
+}
+
+func TestContextCommandStore(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+	m := commandMessage("!motd hello there")
+	m.GuildID = "guild"
+
+	sp.AddContextCommand("motd", "sets the message of the day", func(ctx *EventContext, args []string) interface{} {
+		if ctx.UserID != "user" || ctx.ChannelID != "channel" {
+			t.Errorf("Unexpected context %+v", ctx)
+		}
+		ctx.Store().Set("motd", []byte(strings.Join(args, " ")), 0)
+		return nil
+	})
+	sp.handleCommand(m)
+
+	if v, err := sp.PluginStore("motd").Guild("guild").Get("motd"); err != nil || string(v) != "hello there" {
+		t.Errorf("Expected the value to be stored for the guild - got %q (%v)", v, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no response - got %q", out.String())
+	}
+}
@@ -0,0 +1,128 @@
+package spudo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often the certificate files are checked for changes. Checks
+// happen during TLS handshakes, so an idle server doesn't poll.
+const tlsReloadInterval = 10 * time.Second
+
+// tlsReloader provides the TLS config for the REST API, reloading the
+// certificate, key and client CA bundle when the files change on disk
+// so renewed certificates are picked up without a restart.
+type tlsReloader struct {
+	sync.Mutex
+	certFile  string
+	keyFile   string
+	caFile    string
+	logger    Logger
+	config    *tls.Config
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newTLSReloader(certFile, keyFile, caFile string, logger Logger) (*tlsReloader, error) {
+	tr := &tlsReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		logger:   logger,
+	}
+	var err error
+	if tr.config, err = tr.load(); err != nil {
+		return nil, err
+	}
+	tr.modTimes = tr.currentModTimes()
+	tr.lastCheck = time.Now()
+	return tr, nil
+}
+
+// serverConfig returns the TLS config to give the http.Server, which
+// defers to the reloader on every handshake.
+func (tr *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: tr.getConfigForClient,
+	}
+}
+
+func (tr *tlsReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	tr.Lock()
+	defer tr.Unlock()
+
+	if time.Since(tr.lastCheck) >= tlsReloadInterval {
+		tr.lastCheck = time.Now()
+		tr.reloadIfChanged()
+	}
+	return tr.config, nil
+}
+
+// reloadIfChanged reloads the config if any of the files have a
+// different modification time. If the new files can't be loaded, such
+// as when only the certificate has been replaced so far, the current
+// config is kept.
+func (tr *tlsReloader) reloadIfChanged() {
+	modTimes := tr.currentModTimes()
+	changed := false
+	for f, t := range modTimes {
+		if !t.Equal(tr.modTimes[f]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	config, err := tr.load()
+	if err != nil {
+		tr.logger.Error("Error reloading REST TLS certificates", "error", err)
+		return
+	}
+	tr.config = config
+	tr.modTimes = modTimes
+	tr.logger.Info("Reloaded REST TLS certificates")
+}
+
+func (tr *tlsReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tr.certFile, tr.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if tr.caFile != "" {
+		pem, err := ioutil.ReadFile(tr.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + tr.caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func (tr *tlsReloader) currentModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, f := range []string{tr.certFile, tr.keyFile, tr.caFile} {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			modTimes[f] = info.ModTime()
+		}
+	}
+	return modTimes
+}
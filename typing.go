@@ -0,0 +1,68 @@
+package spudo
+
+import (
+	"sync"
+	"time"
+)
+
+// How often the typing indicator is sent again while a command is
+// running. Discord stops showing it after 10 seconds. It is a variable
+// so tests can shorten it.
+var typingRefresh = 8 * time.Second
+
+// startTyping shows the typing indicator in channelID if the command
+// comStr hasn't finished within TypingDelayMS milliseconds, and keeps it
+// showing until the returned function is called. Once that returns the
+// indicator won't be sent again, so it can't outlast the response.
+func (sp *Spudo) startTyping(channelID, comStr string) func() {
+	if sp.Config.TypingDelayMS <= 0 || !sp.showsTyping(comStr) {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		delay := time.NewTimer(time.Duration(sp.Config.TypingDelayMS) * time.Millisecond)
+		defer delay.Stop()
+		select {
+		case <-done:
+			return
+		case <-delay.C:
+		}
+
+		refresh := time.NewTicker(typingRefresh)
+		defer refresh.Stop()
+		for {
+			if err := sp.backend.Typing(channelID); err != nil {
+				sp.logger.Warn("Failed to send typing indicator", "channel", channelID, "error", err)
+			}
+			select {
+			case <-done:
+				return
+			case <-refresh.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-exited
+		})
+	}
+}
+
+// showsTyping reports whether the typing indicator should be shown
+// while comStr runs. Unknown commands respond immediately, so they
+// don't.
+func (sp *Spudo) showsTyping(comStr string) bool {
+	if _, ok := sp.spudoCommands[comStr]; ok {
+		return true
+	}
+	if com, ok := sp.commands[comStr]; ok {
+		return !com.NoTyping
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package spudo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShowsTyping(t *testing.T) {
+	bot := newSpudo()
+	respond := func(author string, args []string) interface{} { return "done" }
+	bot.AddCommand("slow", "fetches something", respond)
+	bot.AddCommand("quiet", "only reacts", respond, NoTyping())
+
+	if !bot.showsTyping("slow") {
+		t.Error("Expected typing indicator for command")
+	}
+	if bot.showsTyping("quiet") {
+		t.Error("Expected no typing indicator for command added with NoTyping")
+	}
+	if bot.showsTyping("missing") {
+		t.Error("Expected no typing indicator for unknown command")
+	}
+
+	// With no delay configured nothing is started, so stopping must
+	// still be safe
+	bot.startTyping("channel", "slow")()
+}
+
+func TestTypingRefresh(t *testing.T) {
+	defer func(refresh time.Duration) { typingRefresh = refresh }(typingRefresh)
+	typingRefresh = 20 * time.Millisecond
+
+	bot, cb, out := newTestBot(t, func(bot *Spudo) {
+		bot.Config.TypingDelayMS = 10
+		bot.AddCommand("slow", "fetches something", func(author string, args []string) interface{} { return "done" })
+	})
+	defer bot.Shutdown()
+	typed := func() int {
+		cb.mu.Lock()
+		defer cb.mu.Unlock()
+		return strings.Count(out.String(), "spudo is typing...")
+	}
+
+	stop := bot.startTyping("channel", "slow")
+	time.Sleep(5 * time.Millisecond)
+	if n := typed(); n != 0 {
+		t.Errorf("Expected no typing indicator within the delay - got %d", n)
+	}
+	time.Sleep(70 * time.Millisecond)
+	stop()
+	n := typed()
+	if n < 2 {
+		t.Errorf("Expected the typing indicator to be refreshed - sent %d times", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if after := typed(); after != n {
+		t.Errorf("Expected the typing indicator to stop - sent %d more times", after-n)
+	}
+}
@@ -0,0 +1,224 @@
+package spudo
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Name of the webhooks Spudo creates to send messages as.
+const webhookName = "spudo"
+
+var errNotReady = errors.New("bot user isn't known until the session is ready")
+
+// WebhookIdentity is the name and avatar a message sent through a
+// webhook appears to come from. Empty fields use the webhook's own.
+type WebhookIdentity struct {
+	Username  string // Name shown as the author of the message
+	AvatarURL string // URL of the avatar shown for the message
+}
+
+// TimedMessageOption configures a timed message added with
+// AddTimedMessage.
+type TimedMessageOption func(*timedMessage)
+
+// WithWebhook sends a timed message through each channel's webhook as
+// username with the avatar at avatarURL, rather than as the bot.
+func WithWebhook(username, avatarURL string) TimedMessageOption {
+	return func(tm *timedMessage) {
+		tm.Webhook = &WebhookIdentity{Username: username, AvatarURL: avatarURL}
+	}
+}
+
+// ChannelWebhook returns the webhook Spudo uses to send messages to
+// channelID. A webhook the bot created earlier is reused if there is
+// one, otherwise a new one is created, which requires the Manage
+// Webhooks permission.
+func (sp *Spudo) ChannelWebhook(channelID string) (*discordgo.Webhook, error) {
+	mu := sp.webhookLock(channelID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	wh, err := sp.ownWebhook(channelID)
+	if err != nil || wh != nil {
+		return wh, err
+	}
+	if wh, err = sp.backend.CreateWebhook(channelID, webhookName); err != nil {
+		return nil, err
+	}
+	sp.logger.Info("Created channel webhook", "channel", channelID, "webhook", wh.ID)
+
+	sp.Lock()
+	sp.webhooks[channelID] = wh
+	sp.Unlock()
+	return wh, nil
+}
+
+// RemoveChannelWebhook deletes the webhook Spudo uses to send messages
+// to channelID, if it has one.
+func (sp *Spudo) RemoveChannelWebhook(channelID string) error {
+	mu := sp.webhookLock(channelID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	wh, err := sp.ownWebhook(channelID)
+	if err != nil {
+		return err
+	}
+	if wh != nil {
+		sp.forgetWebhook(channelID)
+		if err := sp.backend.DeleteWebhook(wh.ID); err != nil {
+			return err
+		}
+	}
+	// The lock is only needed again if the channel gets a new webhook
+	sp.Lock()
+	delete(sp.webhookLocks, channelID)
+	sp.Unlock()
+	return nil
+}
+
+// webhookLock returns the lock held while looking up, creating or
+// deleting the webhook for channelID, so concurrent sends to a channel
+// without one don't each create a webhook.
+func (sp *Spudo) webhookLock(channelID string) *sync.Mutex {
+	sp.Lock()
+	defer sp.Unlock()
+	mu, ok := sp.webhookLocks[channelID]
+	if !ok {
+		mu = &sync.Mutex{}
+		sp.webhookLocks[channelID] = mu
+	}
+	return mu
+}
+
+// ownWebhook returns the webhook the bot created earlier in channelID,
+// or nil if it hasn't created one.
+func (sp *Spudo) ownWebhook(channelID string) (*discordgo.Webhook, error) {
+	sp.Lock()
+	wh, ok := sp.webhooks[channelID]
+	sp.Unlock()
+	if ok {
+		return wh, nil
+	}
+
+	state := sp.backend.State()
+	if state == nil || state.User == nil {
+		return nil, errNotReady
+	}
+	webhooks, err := sp.backend.ChannelWebhooks(channelID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range webhooks {
+		if w.Name == webhookName && w.Token != "" && w.User != nil && w.User.ID == state.User.ID {
+			sp.Lock()
+			sp.webhooks[channelID] = w
+			sp.Unlock()
+			return w, nil
+		}
+	}
+	return nil, nil
+}
+
+func (sp *Spudo) forgetWebhook(channelID string) {
+	sp.Lock()
+	delete(sp.webhooks, channelID)
+	sp.Unlock()
+}
+
+// SendWebhookMessage sends message to channelID through the channel's
+// webhook, appearing to come from identity. message can be a string,
+// an *Embed, a *MentionResponse or a *discordgo.MessageSend, of which
+// only the content and embed are used. Like SendMessage, it is queued
+// behind the channel's other messages, and content over Discord's
+// length limit is split into several messages with the embed sent in
+// the last. The first message is returned.
+func (sp *Spudo) SendWebhookMessage(channelID string, message interface{}, identity WebhookIdentity) (*discordgo.Message, error) {
+	params, err := webhookParams(message, identity, sp.mentions)
+	if err != nil {
+		return nil, err
+	}
+
+	var first *discordgo.Message
+	for _, p := range splitWebhookPayload(params) {
+		p := p
+		m, err := sp.queue.do(channelID, "webhook", p.WebhookParams, true, func() (*discordgo.Message, error) {
+			return sp.sendWebhook(channelID, p)
+		})
+		sp.metrics.observeSend("webhook", err)
+		if err != nil {
+			sp.logger.Error("Failed to send webhook message", "channel", channelID, "error", err)
+			return first, err
+		}
+		if first == nil {
+			first = m
+		}
+	}
+	return first, nil
+}
+
+func (sp *Spudo) sendWebhook(channelID string, params *webhookPayload) (*discordgo.Message, error) {
+	m, err := sp.executeWebhook(channelID, params)
+	// The webhook may have been deleted by someone else since it was
+	// looked up, try again with a new one
+	if restErr, ok := err.(*discordgo.RESTError); ok && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+		sp.forgetWebhook(channelID)
+		m, err = sp.executeWebhook(channelID, params)
+	}
+	return m, err
+}
+
+func (sp *Spudo) executeWebhook(channelID string, params *webhookPayload) (*discordgo.Message, error) {
+	wh, err := sp.ChannelWebhook(channelID)
+	if err != nil {
+		return nil, err
+	}
+	return sp.backend.ExecuteWebhook(wh.ID, wh.Token, params.WebhookParams, params.AllowedMentions)
+}
+
+// splitWebhookPayload splits the content of p into payloads that fit
+// in a message, only keeping the embeds in the last.
+func splitWebhookPayload(p *webhookPayload) []*webhookPayload {
+	chunks := splitMessage(p.Content, messageLimit)
+	if len(chunks) == 1 {
+		return []*webhookPayload{p}
+	}
+	payloads := make([]*webhookPayload, 0, len(chunks))
+	for i, chunk := range chunks {
+		params := *p.WebhookParams
+		params.Content = chunk
+		if i < len(chunks)-1 {
+			params.Embeds = nil
+		}
+		payloads = append(payloads, &webhookPayload{WebhookParams: &params, AllowedMentions: p.AllowedMentions})
+	}
+	return payloads
+}
+
+func webhookParams(message interface{}, identity WebhookIdentity, am *AllowedMentions) (*webhookPayload, error) {
+	if mr, ok := message.(*MentionResponse); ok {
+		return webhookParams(mr.Message, identity, mr.AllowedMentions)
+	}
+
+	params := &discordgo.WebhookParams{
+		Username:  identity.Username,
+		AvatarURL: identity.AvatarURL,
+	}
+	switch v := message.(type) {
+	case string:
+		params.Content = v
+	case *Embed:
+		params.Embeds = []*discordgo.MessageEmbed{v.MessageEmbed}
+	case *discordgo.MessageSend:
+		params.Content = v.Content
+		if v.Embed != nil {
+			params.Embeds = []*discordgo.MessageEmbed{v.Embed}
+		}
+	default:
+		return nil, errUnsupportedMessage
+	}
+	return &webhookPayload{WebhookParams: params, AllowedMentions: am.normalize()}, nil
+}
@@ -0,0 +1,173 @@
+package spudo
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestWebhookParams(t *testing.T) {
+	identity := WebhookIdentity{Username: "CI", AvatarURL: "https://example.com/ci.png"}
+
+	params, err := webhookParams("Build passed", identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Content != "Build passed" || params.Username != "CI" || params.AvatarURL != identity.AvatarURL {
+		t.Errorf("Unexpected params for string: %+v", params)
+	}
+
+	params, err = webhookParams(NewEmbed().SetTitle("Deploy"), identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Embeds) != 1 || params.Embeds[0].Title != "Deploy" {
+		t.Errorf("Expected embed to be sent - got %+v", params.Embeds)
+	}
+
+	params, err = webhookParams(&discordgo.MessageSend{Content: "hi"}, identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Content != "hi" || len(params.Embeds) != 0 {
+		t.Errorf("Unexpected params for MessageSend: %+v", params)
+	}
+
+	params, err = webhookParams(WithMentions("@everyone", NoMentions()), identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Content != "@everyone" || params.AllowedMentions == nil || len(params.AllowedMentions.Parse) != 0 {
+		t.Errorf("Expected mentions from MentionResponse - got %+v", params.AllowedMentions)
+	}
+
+	if _, err := webhookParams(42, identity, nil); err == nil {
+		t.Error("Expected error for unsupported message type")
+	}
+}
+
+func TestChannelWebhook(t *testing.T) {
+	sp, _, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+
+	// Concurrent sends to a channel without a webhook share one
+	var wg sync.WaitGroup
+	ids := make([]string, 10)
+	for i := range ids {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wh, err := sp.ChannelWebhook("channel")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = wh.ID
+		}()
+	}
+	wg.Wait()
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Fatalf("Expected one webhook to be created - got %q", ids)
+		}
+	}
+	if webhooks, _ := sp.backend.ChannelWebhooks("channel"); len(webhooks) != 1 {
+		t.Errorf("Expected 1 webhook in the channel - got %d", len(webhooks))
+	}
+
+	if err := sp.RemoveChannelWebhook("channel"); err != nil {
+		t.Fatal(err)
+	}
+	if webhooks, _ := sp.backend.ChannelWebhooks("channel"); len(webhooks) != 0 {
+		t.Errorf("Expected the webhook to be deleted - got %d", len(webhooks))
+	}
+	if _, ok := sp.webhookLocks["channel"]; ok {
+		t.Error("Expected the channel's lock to be removed with its webhook")
+	}
+}
+
+func TestRemoveChannelWebhookWithoutWebhook(t *testing.T) {
+	sp, _, _ := newTestBot(t, nil)
+	defer sp.Shutdown()
+
+	if err := sp.RemoveChannelWebhook("channel"); err != nil {
+		t.Fatal(err)
+	}
+	if webhooks, _ := sp.backend.ChannelWebhooks("channel"); len(webhooks) != 0 {
+		t.Errorf("Expected no webhook to be created - got %d", len(webhooks))
+	}
+	if len(sp.webhookLocks) != 0 {
+		t.Errorf("Expected no locks to be kept - got %d", len(sp.webhookLocks))
+	}
+}
+
+func TestChannelWebhookBeforeReady(t *testing.T) {
+	sp := newSpudo()
+	cb := newConsoleBackend(strings.NewReader(""), &bytes.Buffer{}, "user", "channel", "guild")
+	cb.state.User = nil
+	sp.SetBackend(cb)
+
+	if _, err := sp.ChannelWebhook("channel"); err != errNotReady {
+		t.Errorf("Expected errNotReady before the bot user is known - got %v", err)
+	}
+}
+
+// unavailableWebhookBackend is a console backend whose webhooks fail
+// with a transient error.
+type unavailableWebhookBackend struct {
+	*consoleBackend
+}
+
+func (ub unavailableWebhookBackend) ExecuteWebhook(webhookID, token string, params *discordgo.WebhookParams, am *AllowedMentions) (*discordgo.Message, error) {
+	return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}
+}
+
+func TestSendWebhookMessageLongContent(t *testing.T) {
+	sp, _, out := newTestBot(t, nil)
+	defer sp.Shutdown()
+
+	ms := &discordgo.MessageSend{
+		Content: strings.Repeat("step ", 500),
+		Embed:   NewEmbed().SetTitle("Build").MessageEmbed,
+	}
+	m, err := sp.SendWebhookMessage("channel", ms, WebhookIdentity{Username: "CI"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected two messages with the embed in the last - got %q", lines)
+	}
+	// The first line is the returned message, the webhook took ID 1
+	for i, prefix := range []string{"[#channel] CI [webhook] (" + m.ID + "): step", "[#channel] CI [webhook] (3): step", "| title: Build"} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("Expected line %d to start with %q - got %.60q", i, prefix, lines[i])
+		}
+	}
+}
+
+func TestSendWebhookMessageQueued(t *testing.T) {
+	var dead []*DeadLetter
+	sp, _, _ := newTestBot(t, func(sp *Spudo) {
+		sp.Config.SendAttempts = 1
+		sp.SetBackend(unavailableWebhookBackend{sp.backend.(*consoleBackend)})
+		sp.OnDeadLetter("dead", func(dl *DeadLetter) { dead = append(dead, dl) })
+	})
+	defer sp.Shutdown()
+
+	if _, err := sp.SendWebhookMessage("channel", "hello", WebhookIdentity{Username: "CI"}); err == nil {
+		t.Fatal("Expected the send to fail")
+	}
+	if len(dead) != 1 {
+		t.Fatalf("Expected the failed send to go through the queue to the dead letter plugins - got %d", len(dead))
+	}
+	if params, ok := dead[0].Message.(*discordgo.WebhookParams); !ok || params.Content != "hello" || params.Username != "CI" {
+		t.Errorf("Expected the webhook params as the dead letter - got %+v", dead[0].Message)
+	}
+}